@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcp-server/config"
+	"mcp-server/coordinator"
+	"mcp-server/internal/log"
+	"mcp-server/internal/tool"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultConfigPath 默认配置文件路径
+const defaultConfigPath = "config.json"
+
+// configFlag 为命令注册 -config 参数
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", defaultConfigPath, "配置文件路径（支持 .json / .yaml / .yml / .toml）")
+}
+
+// printJSON 以缩进格式输出JSON到标准输出
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// runValidateConfig validate-config命令：加载并校验配置（不初始化工具），或输出配置的JSON Schema
+func runValidateConfig(args []string) error {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	configPath := configFlag(fs)
+	printSchema := fs.Bool("schema", false, "输出配置文件的JSON Schema后退出")
+	fs.Parse(args)
+
+	if *printSchema {
+		return printJSON(config.Schema())
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	enabled := make([]string, 0, len(cfg.Tools))
+	for name := range cfg.Tools {
+		enabled = append(enabled, name)
+	}
+	sort.Strings(enabled)
+	fmt.Printf("配置有效: %s\n启用的工具: %s\n", *configPath, strings.Join(enabled, ", "))
+	return nil
+}
+
+// toolListing list-tools命令的单个工具输出
+type toolListing struct {
+	tool.ToolStatus
+	Descriptor *mcp.Tool `json:"descriptor,omitempty"`
+}
+
+// runListTools list-tools命令：初始化工具并列出状态、描述和输入参数
+func runListTools(args []string) error {
+	fs := flag.NewFlagSet("list-tools", flag.ExitOnError)
+	configPath := configFlag(fs)
+	asJSON := fs.Bool("json", false, "以JSON格式输出完整的工具描述")
+	fs.Parse(args)
+
+	a, err := newApp(context.Background(), *configPath, log.GetLogger())
+	if err != nil {
+		return err
+	}
+	defer a.closeTools(log.GetLogger())
+
+	var listings []toolListing
+	for _, status := range a.toolManager.Status() {
+		listing := toolListing{ToolStatus: status}
+		if t, err := a.toolManager.GetTool(status.Name); err == nil {
+			listing.Descriptor = t.GetDescriptor()
+		}
+		listings = append(listings, listing)
+	}
+	if *asJSON {
+		return printJSON(listings)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tPARAMS\tDESCRIPTION")
+	for _, listing := range listings {
+		if listing.Descriptor == nil {
+			fmt.Fprintf(w, "%s\t%s\t-\t%s\n", listing.Name, listing.Status, listing.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", listing.Name, listing.Status,
+			formatParams(listing.Descriptor.InputSchema), listing.Descriptor.Description)
+	}
+	return w.Flush()
+}
+
+// formatParams 将工具输入参数格式化为 name:type 列表，必填参数带*
+func formatParams(schema mcp.ToolInputSchema) string {
+	required := make(map[string]bool, len(schema.Required))
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		param := name
+		if prop, ok := schema.Properties[name].(map[string]any); ok {
+			if typ, ok := prop["type"].(string); ok {
+				param += ":" + typ
+			}
+		}
+		if required[name] {
+			param += "*"
+		}
+		params = append(params, param)
+	}
+	if len(params) == 0 {
+		return "-"
+	}
+	return strings.Join(params, ",")
+}
+
+// runCallTool call-tool命令：在本地直接调用单个工具并输出结果，便于不经过MCP客户端调试工具
+func runCallTool(args []string) error {
+	fs := flag.NewFlagSet("call-tool", flag.ExitOnError)
+	configPath := configFlag(fs)
+	rawArgs := fs.String("args", "{}", "工具参数（JSON对象）")
+	timeout := fs.Duration("timeout", 0, "调用超时（如 30s），默认不限制")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: mcp-server call-tool <工具名> [--args '{...}'] [参数]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	// 允许工具名出现在参数之前
+	if fs.NArg() > 0 && !strings.HasPrefix(fs.Arg(0), "-") {
+		name := fs.Arg(0)
+		fs.Parse(fs.Args()[1:])
+		if fs.NArg() > 0 {
+			return fmt.Errorf("多余的参数: %s", strings.Join(fs.Args(), " "))
+		}
+		return callTool(*configPath, name, *rawArgs, *timeout)
+	}
+	fs.Usage()
+	return fmt.Errorf("缺少工具名")
+}
+
+// callTool 初始化工具并执行一次调用（与服务中一样经过中间件）
+func callTool(configPath, name, rawArgs string, timeout time.Duration) error {
+	var params map[string]any
+	if err := json.Unmarshal([]byte(rawArgs), &params); err != nil {
+		return fmt.Errorf("解析工具参数失败: %w", err)
+	}
+
+	ctx := context.Background()
+	a, err := newApp(ctx, configPath, log.GetLogger())
+	if err != nil {
+		return err
+	}
+	defer a.closeTools(log.GetLogger())
+	if _, err := a.toolManager.GetTool(name); err != nil {
+		return err
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	result, err := a.toolManager.Call(ctx, name, mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Name:      name,
+			Arguments: params,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("工具执行失败（耗时%s）: %w", time.Since(start).Round(time.Millisecond), err)
+	}
+	if result == nil {
+		return nil
+	}
+
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			fmt.Println(text.Text)
+			continue
+		}
+		if err := printJSON(content); err != nil {
+			return err
+		}
+	}
+	if result.IsError {
+		return fmt.Errorf("工具返回错误（耗时%s）", time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// runPlan plan命令：只生成并校验工具调用计划，不执行任何工具
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: mcp-server plan [参数] \"<查询>\"")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("缺少查询内容")
+	}
+	query := strings.Join(fs.Args(), " ")
+
+	ctx := context.Background()
+	a, err := newApp(ctx, *configPath, log.GetLogger())
+	if err != nil {
+		return err
+	}
+	defer a.closeTools(log.GetLogger())
+	coor, err := a.newCoordinator()
+	if err != nil {
+		return err
+	}
+	plan, usage, err := coor.Plan(ctx, query)
+	if err != nil {
+		return err
+	}
+	return printJSON(struct {
+		Plan  *coordinator.ToolCallPlan `json:"plan"`
+		Usage coordinator.TokenUsage    `json:"usage"`
+	}{plan, usage})
+}
@@ -1,65 +1,211 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"mcp-server/internal/tools/browseruse"
-	"mcp-server/internal/tools/milvus"
-	"mcp-server/internal/tools/search"
+	"go.uber.org/zap"
+	"mcp-server/coordinator"
+	"mcp-server/internal/log"
+	"mcp-server/internal/tool"
 	"os"
+	"sort"
+	"strings"
 )
 
 // AppConfig 应用整体配置
 type AppConfig struct {
-	ServerPort string         `json:"server_port"`
-	Tools      map[string]any `json:"tools"` // 工具配置（动态类型）
+	ServerPort  string                `json:"server_port"`
+	HTTP        HTTPConfig            `json:"http"`        // HTTP服务配置
+	ChatModel   ChatModelConfig       `json:"chat_model"`  // 聊天模型配置（协调器及依赖模型的工具共用）
+	Coordinator coordinator.Config    `json:"coordinator"` // 协调器配置
+	Middleware  tool.MiddlewareConfig `json:"middleware"`  // 工具调用中间件配置
+	Tools       map[string]any        `json:"tools"`       // 工具配置（按工具名，未配置或enabled为false的工具不启用）
+
+	RequiredTools []string            `json:"-"` // 配置了 required: true 的启用工具（就绪探针只要求这些工具可用），由 tools.<name>.required 得出
+	ToolAliases   map[string][]string `json:"-"` // 启用工具的别名（按工具名），由 tools.<name>.aliases 得出
+}
+
+// HTTPConfig HTTP服务配置（SSE、streamable HTTP及自定义接口共用一个服务）
+type HTTPConfig struct {
+	Addr              string `json:"addr"`                                             // 监听地址，为空时为 localhost:<server_port>
+	BasePath          string `json:"base_path"`                                        // 所有路由的路径前缀（如部署在反向代理的子路径下）
+	PublicURL         string `json:"public_url"`                                       // 客户端访问本服务的外部地址（如 https://mcp.example.com），SSE传输据此告知完整的消息端点URL；为空时告知相对路径，由客户端按连接地址解析
+	ReadTimeout       string `json:"read_timeout" jsonschema:"format=duration"`        // 读取整个请求的超时，会中断SSE等长连接，通常保持为空（不限制）
+	ReadHeaderTimeout string `json:"read_header_timeout" jsonschema:"format=duration"` // 读取请求头的超时
+	WriteTimeout      string `json:"write_timeout" jsonschema:"format=duration"`       // 写响应超时，SSE长连接下应保持为空（不限制）
+	IdleTimeout       string `json:"idle_timeout" jsonschema:"format=duration"`        // keep-alive空闲连接超时
+	MaxHeaderBytes    int    `json:"max_header_bytes" jsonschema:"minimum=0"`          // 请求头最大字节数
+	ShutdownTimeout   string `json:"shutdown_timeout" jsonschema:"format=duration"`    // 退出时等待进行中的请求与工具调用完成的最长时间
 }
 
-// LoadConfig 加载配置文件
+// ChatModelConfig OpenAI兼容的聊天模型配置
+type ChatModelConfig struct {
+	APIKey  string `json:"api_key"`  // 建议通过 MCP_CHAT_MODEL_API_KEY(_FILE) 或 api_key_file 提供
+	BaseURL string `json:"base_url"` // 接口地址
+	Model   string `json:"model"`    // 模型名称
+}
+
+// LoadConfig 加载配置文件（按扩展名支持json/yaml/toml），合并默认值与环境变量覆盖（见layer.go）后按JSON Schema校验
+// 工具配置按内置工具注册的配置结构解析（调用方需导入 mcp-server/internal/tools 以注册所有内置工具），
+// 未知的工具名或字段会作为错误返回
 func LoadConfig(path string) (*AppConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read config file failed: %w", err)
 	}
 
+	file, err := decodeFile(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLegacyToolSections(file); err != nil {
+		return nil, err
+	}
+	tree, ignored, err := loadLayers(file, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	if logger := log.GetLogger(); logger != nil && len(ignored) > 0 {
+		logger.Warn("忽略不对应任何配置项的环境变量", zap.Strings("names", ignored))
+	}
+	schema := Schema()
+	normalize(schema, tree)
+	if problems := validate(schema, tree); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	data, err = json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("marshal merged config failed: %w", err)
+	}
+
 	var cfg AppConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parse config failed: %w", err)
 	}
 
-	// 类型转换工具配置（根据实际工具补充）
-	browserCfg := &browseruse.Config{}
-	if err := json.Unmarshal(data, browserCfg); err != nil {
-		return nil, fmt.Errorf("parse browser config failed: %w", err)
+	var raw struct {
+		Tools map[string]json.RawMessage `json:"tools"`
 	}
-	cfg.Tools["browseruse"] = browserCfg
-
-	searchCfg := &search.Config{}
-	if err := json.Unmarshal(data, searchCfg); err != nil {
-		return nil, fmt.Errorf("parse search config failed: %w", err)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse tools config failed: %w", err)
+	}
+	tools, sections, err := decodeTools(raw.Tools)
+	if err != nil {
+		return nil, err
 	}
-	cfg.Tools["web_search"] = searchCfg
-	// 添加Milvus配置解析
-	var rawConfig map[string]json.RawMessage
-	if err := json.Unmarshal(data, &rawConfig); err != nil {
-		return nil, fmt.Errorf("parse raw config failed: %w", err)
+	cfg.Tools = tools
+	cfg.ToolAliases = make(map[string][]string)
+	for name, section := range sections {
+		if section.Required {
+			cfg.RequiredTools = append(cfg.RequiredTools, name)
+		}
+		if len(section.Aliases) > 0 {
+			cfg.ToolAliases[name] = section.Aliases
+		}
+	}
+	sort.Strings(cfg.RequiredTools)
+
+	return &cfg, nil
+}
+
+// legacyToolKeys 旧版配置中名称与工具名不同的顶层工具段落（旧键名 -> 工具名）
+var legacyToolKeys = map[string]string{"search": "web_search"}
+
+// checkLegacyToolSections 旧版配置把工具段落（如 browseruse、search）放在顶层，
+// 这些键不再生效，返回指向 tools.<name> 的错误，而不是笼统的未知字段
+func checkLegacyToolSections(file map[string]any) error {
+	keys := make([]string, 0, len(file))
+	for key := range file {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	if toolsRaw, ok := rawConfig["tools"]; ok {
-		var toolsMap map[string]json.RawMessage
-		if err := json.Unmarshal(toolsRaw, &toolsMap); err != nil {
-			return nil, fmt.Errorf("parse tools config failed: %w", err)
+	var problems []string
+	for _, key := range keys {
+		name := key
+		if renamed, ok := legacyToolKeys[key]; ok {
+			name = renamed
+		}
+		if _, ok := tool.NewBuiltinConfig(name); !ok {
+			continue
 		}
+		problems = append(problems, fmt.Sprintf("%s: tool config must be moved under tools.%s", key, name))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
 
-		// 单独解析milvus配置
-		if milvusRaw, ok := toolsMap["milvus"]; ok {
-			milvusCfg := &milvus.Config{}
-			if err := json.Unmarshal(milvusRaw, milvusCfg); err != nil {
-				return nil, fmt.Errorf("parse milvus config failed: %w", err)
-			}
-			cfg.Tools["milvus"] = milvusCfg
+// toolSection 各工具配置中的公共字段（由配置包处理，不传给工具）
+type toolSection struct {
+	Enabled  *bool    `json:"enabled"`  // 为false时禁用该工具，缺省为启用
+	Required bool     `json:"required"` // 为true时该工具不可用则服务未就绪（/readyz返回503），缺省为可选工具，失败时降级运行
+	Aliases  []string `json:"aliases"`  // 工具的别名（如改名前的旧名称），计划及调用中使用别名时解析到该工具
+}
+
+// toolSectionFields 公共字段的键名
+var toolSectionFields = []string{"enabled", "required", "aliases"}
+
+// decodeTools 将 tools.<name> 解析为各工具注册的配置结构，只返回启用的工具及其公共字段
+// 所有问题（未知工具、未知字段、类型错误）汇总后一并返回
+func decodeTools(sections map[string]json.RawMessage) (map[string]any, map[string]toolSection, error) {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make(map[string]any, len(sections))
+	commons := make(map[string]toolSection, len(sections))
+	var problems []string
+	for _, name := range names {
+		toolCfg, common, err := decodeTool(name, sections[name])
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if common.Enabled != nil && !*common.Enabled {
+			continue
 		}
+		tools[name] = toolCfg
+		commons[name] = common
 	}
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("invalid tools config: %s", strings.Join(problems, "; "))
+	}
+	return tools, commons, nil
+}
 
-	return &cfg, nil
+// decodeTool 解析单个工具的配置，返回配置实例及公共字段
+func decodeTool(name string, section json.RawMessage) (any, toolSection, error) {
+	var common toolSection
+	toolCfg, ok := tool.NewBuiltinConfig(name)
+	if !ok {
+		return nil, common, fmt.Errorf("tools.%s: unknown tool (known tools: %s)", name, strings.Join(tool.BuiltinNames(), ", "))
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(section, &fields); err != nil {
+		return nil, common, fmt.Errorf("tools.%s: %v", name, err)
+	}
+	if err := json.Unmarshal(section, &common); err != nil {
+		return nil, common, fmt.Errorf("tools.%s: %v", name, err)
+	}
+
+	// 公共字段去掉后再严格解析，工具配置结构中不存在的字段视为错误
+	for _, key := range toolSectionFields {
+		delete(fields, key)
+	}
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return nil, common, fmt.Errorf("tools.%s: %v", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(rest))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(toolCfg); err != nil {
+		return nil, common, fmt.Errorf("tools.%s: %v", name, err)
+	}
+	return toolCfg, common, nil
 }
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/json"
+	_ "mcp-server/internal/tools" // 注册内置工具
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeTools(t *testing.T) {
+	sections := map[string]json.RawMessage{
+		"web_search": json.RawMessage(`{"required":true,"aliases":["search"],"timeout":"10s"}`),
+		"milvus":     json.RawMessage(`{"enabled":false,"required":true}`),
+	}
+	tools, commons, err := decodeTools(sections)
+	if err != nil {
+		t.Fatalf("decodeTools() error = %v", err)
+	}
+	if _, ok := tools["web_search"]; !ok || len(tools) != 1 {
+		t.Errorf("enabled tools = %v, want only web_search", tools)
+	}
+	// 禁用的工具即使标记为必需也不计入
+	want := map[string]toolSection{"web_search": {Required: true, Aliases: []string{"search"}}}
+	if !reflect.DeepEqual(commons, want) {
+		t.Errorf("common sections = %+v, want %+v", commons, want)
+	}
+
+	_, _, err = decodeTools(map[string]json.RawMessage{"web_search": json.RawMessage(`{"required":"yes"}`)})
+	if err == nil || !strings.Contains(err.Error(), "tools.web_search") {
+		t.Errorf("decodeTools() error = %v, want type error for tools.web_search", err)
+	}
+}
+
+func TestLoadConfigToolSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "tools:\n  web_search:\n    required: true\n    aliases: [search, ddg]\n  milvus:\n    address: localhost:19530\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(cfg.RequiredTools, []string{"web_search"}) {
+		t.Errorf("RequiredTools = %v", cfg.RequiredTools)
+	}
+	if want := map[string][]string{"web_search": {"search", "ddg"}}; !reflect.DeepEqual(cfg.ToolAliases, want) {
+		t.Errorf("ToolAliases = %v, want %v", cfg.ToolAliases, want)
+	}
+}
+
+func TestLoadConfigLegacyToolSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "顶层的工具段落",
+			content: "browseruse:\n  headless: true\nsearch:\n  timeout: 5s\n",
+			want: []string{
+				"browseruse: tool config must be moved under tools.browseruse",
+				"search: tool config must be moved under tools.web_search",
+			},
+		},
+		{
+			name:    "以工具名为顶层键",
+			content: "server_port: \"8080\"\nmilvus:\n  address: localhost:19530\n",
+			want:    []string{"milvus: tool config must be moved under tools.milvus"},
+		},
+		{
+			name:    "其他未知字段",
+			content: "unknown_section: {}\n",
+			want:    []string{"unknown_section: unknown field"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadConfig(path)
+			if err == nil {
+				t.Fatal("LoadConfig() succeeded, want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadConfig() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"strings"
+)
+
+// decodeFile 按扩展名选择解码器（.json / .yaml / .yml / .toml）将配置文件解析为通用结构
+// 结果统一经过一次JSON编解码，使数字、嵌套对象等与JSON配置的表示一致
+func decodeFile(path string, data []byte) (map[string]any, error) {
+	var file map[string]any
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json", "":
+		err = json.Unmarshal(data, &file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config failed: %w", err)
+	}
+	if file == nil {
+		file = make(map[string]any)
+	}
+
+	normalized := make(map[string]any)
+	if err := roundTrip(file, &normalized); err != nil {
+		return nil, fmt.Errorf("parse config failed: %w", err)
+	}
+	return normalized, nil
+}
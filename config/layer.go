@@ -0,0 +1,302 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"mcp-server/internal/tool"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 配置按以下顺序分层合并，后者覆盖前者：
+//  1. 内置默认值
+//  2. 配置文件（字符串中的 ${VAR} / ${VAR:-默认值} 会替换为环境变量）
+//  3. 环境变量 MCP_<路径>，路径按配置键名大写并以下划线连接，如 MCP_TOOLS_MILVUS_ADDRESS
+//     （只覆盖已知配置键，工具需已在配置文件中配置；不匹配的 MCP_ 变量会被忽略）
+//
+// 机密信息可以通过文件间接提供：配置中的 <键>_file 或环境变量 MCP_<路径>_FILE 指向的文件内容会作为该键的值
+
+// envPrefix 覆盖配置的环境变量前缀
+const envPrefix = "MCP_"
+
+// envPattern 匹配配置字符串中的 ${VAR} 或 ${VAR:-默认值}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// defaults 内置默认配置
+func defaults() map[string]any {
+	return map[string]any{
+		"server_port": "8080",
+		"http": map[string]any{
+			"read_header_timeout": "10s",
+			"idle_timeout":        "2m",
+			"max_header_bytes":    float64(1 << 20),
+			"shutdown_timeout":    "30s",
+		},
+		"chat_model": map[string]any{
+			"base_url": "https://dashscope.aliyuncs.com/compatible-mode/v1",
+			"model":    "qwen-plus",
+		},
+	}
+}
+
+// loadLayers 按默认值、配置文件、环境变量的顺序合并配置，返回合并后的通用结构及未匹配任何配置键而被忽略的环境变量名
+func loadLayers(file map[string]any, environ []string) (map[string]any, []string, error) {
+	interpolate(file)
+
+	known, err := knownKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree := defaults()
+	merge(tree, file)
+	if err := resolveFileRefs(tree, known, ""); err != nil {
+		return nil, nil, err
+	}
+	ignored, err := applyEnv(tree, known, environ)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tree, ignored, nil
+}
+
+// interpolate 将配置中所有字符串值里的 ${VAR} 替换为环境变量，未设置且没有默认值时替换为空
+func interpolate(node any) any {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, child := range v {
+			v[key] = interpolate(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = interpolate(child)
+		}
+	case string:
+		return envPattern.ReplaceAllStringFunc(v, func(match string) string {
+			m := envPattern.FindStringSubmatch(match)
+			if value, ok := os.LookupEnv(m[1]); ok {
+				return value
+			}
+			return m[2]
+		})
+	}
+	return node
+}
+
+// merge 将src深度合并到dst，两边都是对象时递归合并，否则以src为准
+func merge(dst, src map[string]any) {
+	for key, value := range src {
+		srcMap, srcOK := value.(map[string]any)
+		dstMap, dstOK := dst[key].(map[string]any)
+		if srcOK && dstOK {
+			merge(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// knownKeys 返回所有已知配置键组成的树（值为对应类型的零值），用于匹配环境变量路径和推断值类型
+func knownKeys() (map[string]any, error) {
+	var known map[string]any
+	if err := roundTrip(AppConfig{}, &known); err != nil {
+		return nil, err
+	}
+	merge(known, defaults())
+
+	tools := make(map[string]any)
+	for _, name := range tool.BuiltinNames() {
+		toolCfg, _ := tool.NewBuiltinConfig(name)
+		fields := make(map[string]any)
+		if err := roundTrip(toolCfg, &fields); err != nil {
+			return nil, err
+		}
+		fields["enabled"] = false
+		fields["required"] = false
+		fields["aliases"] = []any{}
+		tools[name] = fields
+	}
+	known["tools"] = tools
+	return known, nil
+}
+
+// roundTrip 通过JSON编解码将v转换为通用结构
+func roundTrip(v any, out any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal config failed: %w", err)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// resolveFileRefs 将配置中的 <键>_file 替换为文件内容（<键>_file本身不是已知配置键时）
+func resolveFileRefs(tree, known map[string]any, path string) error {
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		knownChild, _ := known[key].(map[string]any)
+		if child, ok := tree[key].(map[string]any); ok {
+			if err := resolveFileRefs(child, knownChild, path+key+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		filePath, ok := tree[key].(string)
+		base, isRef := strings.CutSuffix(key, "_file")
+		if _, isKnown := known[key]; !ok || !isRef || isKnown {
+			continue
+		}
+		value, err := readSecret(filePath)
+		if err != nil {
+			return fmt.Errorf("%s%s: %w", path, key, err)
+		}
+		delete(tree, key)
+		tree[base] = value
+	}
+	return nil
+}
+
+// readSecret 读取机密文件内容（去掉首尾空白）
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read secret file failed: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// applyEnv 用 MCP_ 前缀的环境变量覆盖配置，*_FILE 先于同名的直接赋值处理
+// 只应用路径对应已知配置键的变量，返回因不匹配任何配置键而被忽略的变量名（环境中可能有其他程序使用的 MCP_ 变量）
+func applyEnv(tree, known map[string]any, environ []string) ([]string, error) {
+	var direct, files []string
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, envPrefix) || name == envPrefix {
+			continue
+		}
+		if strings.HasSuffix(name, "_FILE") {
+			files = append(files, kv)
+		} else {
+			direct = append(direct, kv)
+		}
+	}
+	sort.Strings(direct)
+	sort.Strings(files)
+
+	var ignored []string
+	for _, kv := range append(files, direct...) {
+		name, value, _ := strings.Cut(kv, "=")
+		path := strings.TrimPrefix(name, envPrefix)
+		segments := strings.Split(strings.ToLower(path), "_")
+		if base, ok := strings.CutSuffix(path, "_FILE"); ok {
+			baseSegments := strings.Split(strings.ToLower(base), "_")
+			if !hasPath(tree, known, "", baseSegments) {
+				ignored = append(ignored, name)
+				continue
+			}
+			secret, err := readSecret(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			segments, value = baseSegments, secret
+		}
+		if !setPath(tree, known, "", segments, value) {
+			ignored = append(ignored, name)
+		}
+	}
+	return ignored, nil
+}
+
+// hasPath 判断环境变量路径能否匹配到配置键（不修改配置）
+func hasPath(tree, known map[string]any, prefix string, segments []string) bool {
+	return setPath(copyTree(tree), known, prefix, segments, "")
+}
+
+// copyTree 深拷贝配置中的对象（其他值共享）
+func copyTree(tree map[string]any) map[string]any {
+	out := make(map[string]any, len(tree))
+	for key, value := range tree {
+		if child, ok := value.(map[string]any); ok {
+			value = copyTree(child)
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// setPath 按环境变量路径设置配置值，路径不对应任何配置键时不修改配置并返回false
+// 键名本身可能含下划线，因此每一层优先匹配最长的键。可匹配的键为：
+//   - 配置结构或默认值中的已知键，缺少的中间对象会自动创建
+//   - 动态键（如 coordinator.policies 下的工具名）只匹配配置中已有的键，不会新建条目
+//
+// 工具段 tools.<name> 只能由配置文件启用，环境变量只覆盖已配置工具的字段，避免设置一个地址就意外启用工具
+func setPath(tree, known map[string]any, prefix string, segments []string, value string) bool {
+	for n := len(segments); n >= 1; n-- {
+		key := strings.Join(segments[:n], "_")
+		current, inTree := tree[key]
+		hint, inKnown := known[key]
+		if !inKnown && !(inTree && known == nil) {
+			continue
+		}
+		if inTree {
+			hint = current
+		}
+
+		if n == len(segments) {
+			tree[key] = coerce(value, hint)
+			return true
+		}
+
+		// 路径还有剩余，只能进入对象
+		knownChild, knownIsMap := known[key].(map[string]any)
+		child, ok := current.(map[string]any)
+		if !ok {
+			if current != nil || !knownIsMap || prefix == "tools." {
+				continue
+			}
+			child = make(map[string]any)
+		}
+		if setPath(child, knownChild, prefix+key+".", segments[n:], value) {
+			tree[key] = child
+			return true
+		}
+	}
+	return false
+}
+
+// coerce 按已有值的类型转换环境变量的字符串值，转换失败时保留字符串（解析配置时报告类型错误）
+func coerce(value string, hint any) any {
+	switch hint.(type) {
+	case bool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case float64:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case []any:
+		var list []any
+		if err := json.Unmarshal([]byte(value), &list); err == nil {
+			return list
+		}
+		for _, item := range strings.Split(value, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+		return list
+	case map[string]any:
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(value), &obj); err == nil {
+			return obj
+		}
+	}
+	return value
+}
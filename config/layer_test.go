@@ -0,0 +1,157 @@
+package config
+
+import (
+	_ "mcp-server/internal/tools" // 注册内置工具，tools.<name> 才是已知配置键
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lookup 按点分路径取配置值
+func lookup(tree map[string]any, path string) (any, bool) {
+	var current any = tree
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func TestLoadLayersEnv(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "api_key")
+	if err := os.WriteFile(secret, []byte("  sk-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		file        map[string]any
+		env         []string
+		want        map[string]any // 路径 -> 期望值
+		absent      []string       // 不应出现的路径
+		wantIgnored []string
+	}{
+		{
+			name: "覆盖已知键并按默认值类型转换",
+			env:  []string{"MCP_SERVER_PORT=9090", "MCP_HTTP_SHUTDOWN_TIMEOUT=5s", "MCP_HTTP_MAX_HEADER_BYTES=4096"},
+			want: map[string]any{"server_port": "9090", "http.shutdown_timeout": "5s", "http.max_header_bytes": 4096.0},
+		},
+		{
+			name: "创建配置结构中已知的中间对象",
+			env:  []string{"MCP_COORDINATOR_MAX_CONCURRENCY=8", "MCP_COORDINATOR_SYNTHESIZE=true", "MCP_MIDDLEWARE_CACHE_BACKEND=disk"},
+			want: map[string]any{"coordinator.max_concurrency": 8.0, "coordinator.synthesize": true, "middleware.cache.backend": "disk"},
+		},
+		{
+			name:        "忽略不对应配置键的变量",
+			env:         []string{"MCP_SOMETHING_ELSE=1", "MCP_HTTP_NOPE=1", "MCP_=x", "OTHER=1"},
+			absent:      []string{"something_else", "something", "http.nope"},
+			wantIgnored: []string{"MCP_HTTP_NOPE", "MCP_SOMETHING_ELSE"},
+		},
+		{
+			name:        "不会通过环境变量启用未配置的工具",
+			env:         []string{"MCP_TOOLS_MILVUS_ADDRESS=localhost:19530", "MCP_TOOLS_SEARCH_ENABLED=true"},
+			absent:      []string{"tools"},
+			wantIgnored: []string{"MCP_TOOLS_MILVUS_ADDRESS", "MCP_TOOLS_SEARCH_ENABLED"},
+		},
+		{
+			name: "覆盖已配置工具的字段",
+			file: map[string]any{"tools": map[string]any{"milvus": map[string]any{"collection": "docs"}}},
+			env:  []string{"MCP_TOOLS_MILVUS_ADDRESS=localhost:19530", "MCP_TOOLS_MILVUS_TOP_K=3", "MCP_TOOLS_MILVUS_ENABLED=false"},
+			want: map[string]any{
+				"tools.milvus.address":    "localhost:19530",
+				"tools.milvus.top_k":      3.0,
+				"tools.milvus.enabled":    false,
+				"tools.milvus.collection": "docs",
+			},
+			absent: []string{"tools.search"},
+		},
+		{
+			name: "动态键只匹配配置中已有的条目",
+			file: map[string]any{"coordinator": map[string]any{"policies": map[string]any{"web_search": map[string]any{"fallback": "vector_search"}}}},
+			env:  []string{"MCP_COORDINATOR_POLICIES_WEB_SEARCH_FALLBACK=none", "MCP_COORDINATOR_POLICIES_VECTOR_SEARCH_FALLBACK=web_search"},
+			want: map[string]any{"coordinator.policies.web_search.fallback": "none"},
+			absent: []string{
+				"coordinator.policies.vector_search",
+				"coordinator.policies.vector",
+			},
+			wantIgnored: []string{"MCP_COORDINATOR_POLICIES_VECTOR_SEARCH_FALLBACK"},
+		},
+		{
+			name:        "从文件读取机密，未知键的_FILE变量不读取文件",
+			env:         []string{"MCP_CHAT_MODEL_API_KEY_FILE=" + secret, "MCP_CHAT_MODEL_TOKEN_FILE=/nonexistent"},
+			want:        map[string]any{"chat_model.api_key": "sk-secret"},
+			wantIgnored: []string{"MCP_CHAT_MODEL_TOKEN_FILE"},
+		},
+		{
+			name: "直接赋值优先于_FILE",
+			env:  []string{"MCP_CHAT_MODEL_API_KEY=sk-direct", "MCP_CHAT_MODEL_API_KEY_FILE=" + secret},
+			want: map[string]any{"chat_model.api_key": "sk-direct"},
+		},
+		{
+			name: "配置文件中的_file引用",
+			file: map[string]any{"chat_model": map[string]any{"api_key_file": secret}},
+			want: map[string]any{"chat_model.api_key": "sk-secret", "chat_model.model": "qwen-plus"},
+			absent: []string{
+				"chat_model.api_key_file",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := tt.file
+			if file == nil {
+				file = map[string]any{}
+			}
+			tree, ignored, err := loadLayers(file, tt.env)
+			if err != nil {
+				t.Fatalf("loadLayers() error = %v", err)
+			}
+			for path, want := range tt.want {
+				got, ok := lookup(tree, path)
+				if !ok || !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %#v (present %v), want %#v", path, got, ok, want)
+				}
+			}
+			for _, path := range tt.absent {
+				if got, ok := lookup(tree, path); ok {
+					t.Errorf("%s = %#v, want absent", path, got)
+				}
+			}
+			if !reflect.DeepEqual(ignored, tt.wantIgnored) {
+				t.Errorf("ignored = %v, want %v", ignored, tt.wantIgnored)
+			}
+		})
+	}
+}
+
+func TestLoadLayersSecretFileError(t *testing.T) {
+	_, _, err := loadLayers(map[string]any{}, []string{"MCP_CHAT_MODEL_API_KEY_FILE=/nonexistent/key"})
+	if err == nil || !strings.Contains(err.Error(), "MCP_CHAT_MODEL_API_KEY_FILE") {
+		t.Fatalf("loadLayers() error = %v, want secret file error", err)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("MCP_TEST_HOST", "db.internal")
+	file := map[string]any{
+		"tools": map[string]any{"milvus": map[string]any{
+			"address": "${MCP_TEST_HOST}:${MCP_TEST_PORT:-19530}",
+			"list":    []any{"${MCP_TEST_UNSET}", 1.0},
+		}},
+	}
+	interpolate(file)
+	milvus := file["tools"].(map[string]any)["milvus"].(map[string]any)
+	if got := milvus["address"]; got != "db.internal:19530" {
+		t.Errorf("address = %v", got)
+	}
+	if got := milvus["list"]; !reflect.DeepEqual(got, []any{"", 1.0}) {
+		t.Errorf("list = %v", got)
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"fmt"
+	"mcp-server/internal/jsonvalue"
+	"mcp-server/internal/tool"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 配置结构字段可以通过 jsonschema 标签声明约束，多个约束以逗号分隔：
+//
+//	minimum=1            数值下限
+//	maximum=100          数值上限
+//	enum=react|plan      取值范围
+//	format=duration      Go时长字符串（如"10s"）
+
+// Schema 生成 AppConfig 及所有内置工具配置的JSON Schema
+func Schema() map[string]any {
+	root := schemaFor(reflect.TypeOf(AppConfig{}))
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "mcp-server config"
+
+	tools := make(map[string]any)
+	for _, name := range tool.BuiltinNames() {
+		toolCfg, _ := tool.NewBuiltinConfig(name)
+		s := schemaFor(reflect.TypeOf(toolCfg))
+		if props, ok := s["properties"].(map[string]any); ok {
+			props["enabled"] = map[string]any{"type": "boolean"}
+			props["required"] = map[string]any{"type": "boolean"}
+			props["aliases"] = map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+		}
+		tools[name] = s
+	}
+	root["properties"].(map[string]any)["tools"] = map[string]any{
+		"type":                 "object",
+		"properties":           tools,
+		"additionalProperties": false,
+	}
+	return root
+}
+
+// schemaFor 按Go类型生成JSON Schema（字段名取自json标签）
+func schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		props := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := jsonName(field)
+			if name == "" {
+				continue
+			}
+			s := schemaFor(field.Type)
+			applyTag(s, field.Tag.Get("jsonschema"))
+			props[name] = s
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	default:
+		// interface等无法确定类型的字段不做约束
+		return map[string]any{}
+	}
+}
+
+// jsonName 返回字段的JSON键名，不参与编解码的字段返回空
+func jsonName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// applyTag 将 jsonschema 标签中的约束写入Schema
+func applyTag(s map[string]any, tag string) {
+	if tag == "" {
+		return
+	}
+	for _, item := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch key {
+		case "minimum", "maximum":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				s[key] = f
+			}
+		case "enum":
+			var enum []any
+			for _, v := range strings.Split(value, "|") {
+				enum = append(enum, v)
+			}
+			s["enum"] = enum
+		case "format":
+			s["format"] = value
+		}
+	}
+}
+
+// normalize 按Schema调整配置中的标量：字符串类型的键写成整数时（如YAML/TOML中的 server_port: 8080）转换为字符串
+func normalize(schema map[string]any, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		extra, _ := schema["additionalProperties"].(map[string]any)
+		for key, child := range v {
+			if s, ok := props[key].(map[string]any); ok {
+				v[key] = normalize(s, child)
+			} else if extra != nil {
+				v[key] = normalize(extra, child)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				v[i] = normalize(items, item)
+			}
+		}
+	default:
+		if schema["type"] == "string" && jsonvalue.IsInteger(value) {
+			f, _ := jsonvalue.Number(value)
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	}
+	return value
+}
+
+// validate 按Schema校验合并后的配置，返回带路径的问题描述（如 tools.milvus.top_k: must be >= 1）
+func validate(schema map[string]any, value any) []string {
+	var problems []string
+	validateNode(schema, value, "", &problems)
+	return problems
+}
+
+// validateNode 递归校验单个节点
+func validateNode(schema map[string]any, value any, path string, problems *[]string) {
+	report := func(format string, args ...any) {
+		name := path
+		if name == "" {
+			name = "config"
+		}
+		*problems = append(*problems, name+": "+fmt.Sprintf(format, args...))
+	}
+
+	// null视为未设置，总是允许
+	if value == nil {
+		return
+	}
+	if typ, ok := schema["type"].(string); ok && !jsonvalue.MatchesType(value, typ) {
+		report("must be %s, got %s", typ, jsonvalue.TypeName(value))
+		return
+	}
+
+	if f, ok := jsonvalue.Number(value); ok {
+		if min, ok := schema["minimum"].(float64); ok && f < min {
+			report("must be >= %v", min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && f > max {
+			report("must be <= %v", max)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(value) == fmt.Sprint(allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("must be one of %v, got %q", enum, fmt.Sprint(value))
+		}
+	}
+
+	if schema["format"] == "duration" {
+		if s, ok := value.(string); ok && s != "" {
+			if _, err := time.ParseDuration(s); err != nil {
+				report("must be a duration such as \"10s\", got %q", s)
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := joinPath(path, key)
+			if s, ok := props[key].(map[string]any); ok {
+				validateNode(s, v[key], child, problems)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					*problems = append(*problems, child+": unknown field")
+				}
+			case map[string]any:
+				validateNode(extra, v[key], child, problems)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateNode(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+// joinPath 拼接配置路径
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name:    "YAML整数端口",
+			file:    "config.yaml",
+			content: "server_port: 8080\nhttp:\n  max_header_bytes: 4096\ncoordinator:\n  max_steps: 3\n",
+		},
+		{
+			name:    "TOML整数端口",
+			file:    "config.toml",
+			content: "server_port = 8080\n[http]\nmax_header_bytes = 4096\n[coordinator]\nmax_steps = 3\n",
+		},
+		{
+			name:    "JSON字符串端口",
+			file:    "config.json",
+			content: `{"server_port":"8080","http":{"max_header_bytes":4096},"coordinator":{"max_steps":3}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.ServerPort != "8080" || cfg.HTTP.MaxHeaderBytes != 4096 || cfg.Coordinator.MaxSteps != 3 {
+				t.Errorf("LoadConfig() = port %q, max_header_bytes %d, max_steps %d",
+					cfg.ServerPort, cfg.HTTP.MaxHeaderBytes, cfg.Coordinator.MaxSteps)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		tree map[string]any
+		want []string
+	}{
+		{
+			name: "合法配置",
+			tree: map[string]any{"server_port": "8080", "coordinator": map[string]any{"mode": "plan", "max_steps": 2.0}},
+		},
+		{
+			name: "YAML/TOML解码出的Go整数",
+			tree: map[string]any{"http": map[string]any{"max_header_bytes": 4096}, "coordinator": map[string]any{"max_steps": int64(2)}},
+		},
+		{
+			name: "类型、范围、枚举、时长与未知字段",
+			tree: map[string]any{
+				"server_port": true,
+				"unknown":     1.0,
+				"http":        map[string]any{"shutdown_timeout": "soon", "max_header_bytes": 1.5},
+				"coordinator": map[string]any{"mode": "auto", "max_steps": -1.0},
+				"tools":       map[string]any{"milvus": map[string]any{"top_k": 0.0, "bogus": "x"}, "nope": map[string]any{}},
+			},
+			want: []string{
+				"coordinator.max_steps: must be >= 0",
+				`coordinator.mode: must be one of [react plan], got "auto"`,
+				"http.max_header_bytes: must be integer, got number",
+				`http.shutdown_timeout: must be a duration such as "10s", got "soon"`,
+				"server_port: must be string, got boolean",
+				"tools.milvus.bogus: unknown field",
+				"tools.milvus.top_k: must be >= 1",
+				"tools.nope: unknown field",
+				"unknown: unknown field",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validate(Schema(), tt.tree)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tree := map[string]any{
+		"server_port": 8080.0,
+		"chat_model":  map[string]any{"model": int64(4)},
+		"http":        map[string]any{"max_header_bytes": 4096.0, "addr": 1.5},
+	}
+	normalize(Schema(), tree)
+	want := map[string]any{
+		"server_port": "8080",
+		"chat_model":  map[string]any{"model": "4"},
+		"http":        map[string]any{"max_header_bytes": 4096.0, "addr": 1.5},
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("normalize() = %v, want %v", tree, want)
+	}
+}
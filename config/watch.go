@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// defaultWatchInterval 默认的配置文件检查间隔
+const defaultWatchInterval = 2 * time.Second
+
+// Watcher 监听配置文件变化，文件修改或进程收到SIGHUP时重新加载配置
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onReload func(cfg *AppConfig, err error)
+}
+
+// NewWatcher 创建配置监听器，onReload在每次重新加载后调用（加载失败时cfg为nil）
+func NewWatcher(path string, interval time.Duration, onReload func(cfg *AppConfig, err error)) *Watcher {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		onReload: onReload,
+	}
+}
+
+// Run 开始监听，直到ctx结束
+// 通过轮询文件的修改时间和大小判断变化，避免引入额外的文件系统通知依赖
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(w.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			last, _ = os.Stat(w.path)
+			w.reload()
+		case <-ticker.C:
+			info, err := os.Stat(w.path)
+			if err != nil || !changed(last, info) {
+				continue
+			}
+			last = info
+			w.reload()
+		}
+	}
+}
+
+// reload 重新加载配置并回调
+func (w *Watcher) reload() {
+	cfg, err := LoadConfig(w.path)
+	w.onReload(cfg, err)
+}
+
+// changed 判断文件信息是否发生变化
+func changed(before, after os.FileInfo) bool {
+	if before == nil {
+		return after != nil
+	}
+	return !before.ModTime().Equal(after.ModTime()) || before.Size() != after.Size()
+}
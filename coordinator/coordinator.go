@@ -2,114 +2,325 @@ package coordinator
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/cloudwego/eino-ext/components/model/openai"
+	acl "github.com/cloudwego/eino-ext/libs/acl/openai"
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
-	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
 	"mcp-server/internal/tool"
 	"strings"
+	"time"
 )
 
 // ToolCallStep 模型生成的工具调用步骤
 type ToolCallStep struct {
-	ToolName string                 `json:"tool_name"` // 工具名称（如"web_search"）
-	Params   map[string]interface{} `json:"params"`    // 工具参数
-	Reason   string                 `json:"reason"`    // 调用原因（用于上下文追溯）
+	ToolName  string                 `json:"tool_name"`  // 工具名称（如"web_search"）
+	Params    map[string]interface{} `json:"params"`     // 工具参数
+	Reason    string                 `json:"reason"`     // 调用原因（用于上下文追溯）
+	DependsOn []int                  `json:"depends_on"` // 依赖的步骤下标（从0开始），参数中引用的步骤会自动加入
+	// ContinueOnError 为true时该步骤失败不会中止整个计划（适用于非关键步骤）
+	ContinueOnError bool `json:"continue_on_error"`
 }
 
 // ToolCallPlan 模型生成的工具调用计划
 type ToolCallPlan struct {
-	Steps []ToolCallStep `json:"steps"` // 工具调用步骤列表（按顺序执行）
+	Steps []ToolCallStep `json:"steps"` // 工具调用步骤列表（按依赖关系并发执行）
+}
+
+// 协调器运行模式
+const (
+	ModeReAct = "react" // 原生函数调用循环（默认）
+	ModePlan  = "plan"  // 一次性生成JSON调用计划后顺序执行
+)
+
+// defaultMaxSteps ReAct模式下默认的最大模型-工具交互轮数
+const defaultMaxSteps = 8
+
+// Config 协调器配置
+type Config struct {
+	Mode              string            `json:"mode" jsonschema:"enum=react|plan"`          // 运行模式（react / plan），默认react
+	MaxSteps          int               `json:"max_steps" jsonschema:"minimum=0"`           // ReAct模式下最多的模型调用轮数，超出后终止
+	MaxConcurrency    int               `json:"max_concurrency" jsonschema:"minimum=0"`     // 计划模式下最多同时执行的步骤数
+	Synthesize        bool              `json:"synthesize"`                                 // 计划模式下是否将各步骤结果交给模型汇总为自然语言回答
+	MaxRepairAttempts int               `json:"max_repair_attempts" jsonschema:"minimum=0"` // 计划解析或校验失败后允许模型修正的次数
+	JSONMode          bool              `json:"json_mode"`                                  // 生成计划时要求模型以JSON对象格式输出（需端点支持response_format）
+	Session           SessionConfig     `json:"session"`                                    // 会话历史配置
+	Policies          map[string]Policy `json:"policies"`                                   // 按工具名配置的重试与后备策略（超时见 middleware.tools.<name>.timeout）
+}
+
+// 步骤执行状态
+const (
+	StepSucceeded = "succeeded" // 执行成功
+	StepFailed    = "failed"    // 执行失败
+	StepSkipped   = "skipped"   // 因前序失败等原因未执行
+)
+
+// StepResult 单个工具调用步骤的执行记录
+type StepResult struct {
+	Step       int                    `json:"step"`               // 步骤序号（从1开始，与回答中的[步骤N]标注对应）
+	ToolName   string                 `json:"tool_name"`          // 工具名称
+	Params     map[string]interface{} `json:"params"`             // 实际调用参数（已解析引用）
+	Reason     string                 `json:"reason"`             // 调用原因
+	Status     string                 `json:"status"`             // 执行状态（succeeded / failed / skipped）
+	DurationMs int64                  `json:"duration_ms"`        // 执行耗时（毫秒）
+	Output     string                 `json:"output"`             // 工具返回的原始文本
+	Error      string                 `json:"error,omitempty"`    // 失败原因
+	Attempts   int                    `json:"attempts"`           // 实际调用次数（含重试及后备工具）
+	Fallback   string                 `json:"fallback,omitempty"` // 使用了后备工具时为后备工具名
+}
+
+// TokenUsage 一次查询累计的模型token用量
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// add 累加一次模型调用的token用量
+func (u *TokenUsage) add(msg *schema.Message) {
+	if msg == nil || msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		return
+	}
+	u.PromptTokens += msg.ResponseMeta.Usage.PromptTokens
+	u.CompletionTokens += msg.ResponseMeta.Usage.CompletionTokens
+	u.TotalTokens += msg.ResponseMeta.Usage.TotalTokens
+}
+
+// Result 一次查询的完整处理结果
+type Result struct {
+	Answer string        `json:"answer"`         // 最终回答
+	Plan   *ToolCallPlan `json:"plan,omitempty"` // 模型生成的调用计划（仅计划模式）
+	Steps  []StepResult  `json:"steps"`          // 各步骤的原始执行记录
+	Usage  TokenUsage    `json:"usage"`          // 模型token用量
 }
 
 // Coordinator 流程协调器
 type Coordinator struct {
 	model       *openai.ChatModel // 大模型实例（用于生成工具调用计划）
 	toolManager *tool.ToolManager // 工具管理器（用于获取工具实例）
-	ctx         context.Context   // 上下文
+	cfg         Config            // 协调器配置（已填充默认值）
+	sessions    SessionStore      // 会话历史存储
+	policies    map[string]policy // 按工具名解析后的调用策略
+	logger      *zap.Logger       // 记录不影响回答的错误（如会话历史保存失败）
+}
+
+// Option 协调器可选项
+type Option func(c *Coordinator)
+
+// WithSessionStore 指定会话历史存储（默认使用默认参数的内存存储）
+func WithSessionStore(store SessionStore) Option {
+	return func(c *Coordinator) {
+		c.sessions = store
+	}
+}
+
+// WithLogger 指定日志（默认不输出）
+func WithLogger(logger *zap.Logger) Option {
+	return func(c *Coordinator) {
+		c.logger = logger
+	}
 }
 
 // NewCoordinator 创建协调器实例
-func NewCoordinator(ctx context.Context, model *openai.ChatModel, toolManager *tool.ToolManager) *Coordinator {
-	return &Coordinator{
+func NewCoordinator(model *openai.ChatModel, toolManager *tool.ToolManager, cfg *Config, opts ...Option) *Coordinator {
+	c := &Coordinator{
 		model:       model,
 		toolManager: toolManager,
-		ctx:         ctx,
 	}
+	if cfg != nil {
+		c.cfg = *cfg
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.policies = parsePolicies(c.cfg.Policies)
+	if c.logger == nil {
+		c.logger = zap.NewNop()
+	}
+	if c.sessions == nil {
+		c.sessions = NewMemorySessionStore(defaultMaxHistory, defaultSessionTTL)
+	}
+	if c.cfg.Mode == "" {
+		c.cfg.Mode = ModeReAct
+	}
+	if c.cfg.MaxSteps <= 0 {
+		c.cfg.MaxSteps = defaultMaxSteps
+	}
+	if c.cfg.MaxConcurrency <= 0 {
+		c.cfg.MaxConcurrency = defaultMaxConcurrency
+	}
+	if c.cfg.MaxRepairAttempts <= 0 {
+		c.cfg.MaxRepairAttempts = defaultMaxRepairAttempts
+	}
+	return c
 }
 
-// Run 执行用户查询的完整处理流程（模型驱动工具调用）
-func (c *Coordinator) Run(userQuery string) (string, error) {
-	// 1. 生成工具调用计划（通过大模型分析用户查询，决定需要调用的工具及顺序）
-	plan, err := c.generateToolCallPlan(userQuery)
+// Run 执行用户查询的完整处理流程（模型驱动工具调用），只返回最终回答
+func (c *Coordinator) Run(ctx context.Context, userQuery string) (string, error) {
+	result, err := c.Execute(ctx, "", userQuery)
 	if err != nil {
-		return "", fmt.Errorf("生成工具调用计划失败: %v", err)
+		return "", err
 	}
+	return result.Answer, nil
+}
 
-	// 2. 按顺序执行工具调用步骤
-	var finalResult string
-	for i, step := range plan.Steps {
-		// 2.1 获取工具实例
-		tool, err := c.toolManager.GetTool(step.ToolName)
+// Execute 执行用户查询，返回最终回答及各步骤的执行记录
+// sessionID非空时会带上该会话的历史问答，并在成功后记录本轮（记录失败只写日志，不影响已得到的回答）；为空时不保留上下文
+// 执行失败时仍返回已产生的部分记录，便于定位失败的步骤
+func (c *Coordinator) Execute(ctx context.Context, sessionID, userQuery string) (*Result, error) {
+	return c.ExecuteStream(ctx, sessionID, userQuery, nil)
+}
+
+// ExecuteStream 与Execute相同，但在执行过程中通过handler推送计划、步骤、token增量及最终回答等进度事件
+// ctx结束（如客户端断开）时取消进行中的模型调用与工具调用；sessionID同时作为客户端标识随工具调用传递，按客户端的限流据此区分调用方
+func (c *Coordinator) ExecuteStream(ctx context.Context, sessionID, userQuery string, handler EventHandler) (*Result, error) {
+	if sessionID != "" {
+		ctx = tool.WithClientID(ctx, sessionID)
+	}
+
+	var history []Turn
+	if sessionID != "" {
+		turns, err := c.sessions.History(ctx, sessionID)
 		if err != nil {
-			return "", fmt.Errorf("步骤%d: 工具 %s 不存在: %v", i+1, step.ToolName, err)
+			return nil, fmt.Errorf("读取会话历史失败: %v", err)
 		}
+		history = turns
+	}
 
-		// 2.2 构造工具调用请求
-		req := mcp.CallToolRequest{
-			Params: mcp.CallToolParams{
-				Arguments: step.Params,
-			},
-		}
+	var result *Result
+	var err error
+	if c.cfg.Mode == ModePlan {
+		result, err = c.runPlan(ctx, userQuery, history, handler)
+	} else {
+		result, err = c.runReAct(ctx, userQuery, history, handler)
+	}
+	if err != nil {
+		return result, err
+	}
 
-		// 2.3 执行工具调用
-		result, err := tool.Execute(c.ctx, req)
-		if err != nil || result.IsError {
-			return "", fmt.Errorf("步骤%d: 工具 %s 执行失败: %v / %s", i+1, step.ToolName, err, result.Content)
+	if sessionID != "" {
+		turn := Turn{
+			Query:  userQuery,
+			Plan:   result.Plan,
+			Answer: result.Answer,
+			Time:   time.Now(),
 		}
-
-		// 2.4 记录中间结果（可选：将结果追加到对话上下文中，供模型后续步骤使用）
-		content, ok := result.Content[0].(mcp.TextContent)
-		if !ok {
-			return "", fmt.Errorf("不支持的返回内容类型")
+		if err := c.sessions.Append(ctx, sessionID, turn); err != nil {
+			c.logger.Warn("保存会话历史失败", zap.String("session_id", sessionID), zap.Error(err))
 		}
-		finalResult = content.Text
 	}
+	handler.emit(Event{Type: EventFinalAnswer, Answer: result.Answer})
+	return result, nil
+}
 
-	return finalResult, nil
+// Plan 只生成并校验工具调用计划而不执行（用于调试提示词与工具描述），不读写会话历史
+func (c *Coordinator) Plan(ctx context.Context, userQuery string) (*ToolCallPlan, TokenUsage, error) {
+	var usage TokenUsage
+	plan, err := c.generateToolCallPlan(ctx, userQuery, nil, &usage)
+	return plan, usage, err
 }
 
+// runPlan 先让模型生成完整的工具调用计划，再按依赖关系执行
+func (c *Coordinator) runPlan(ctx context.Context, userQuery string, history []Turn, handler EventHandler) (*Result, error) {
+	result := &Result{}
+
+	// 1. 生成工具调用计划（通过大模型分析用户查询，决定需要调用的工具及顺序）
+	plan, err := c.generateToolCallPlan(ctx, userQuery, history, &result.Usage)
+	if err != nil {
+		return result, fmt.Errorf("生成工具调用计划失败: %v", err)
+	}
+	result.Plan = plan
+	handler.emit(Event{Type: EventPlanGenerated, Plan: plan})
+
+	// 2. 按依赖关系执行工具调用步骤（无依赖的步骤并发执行）
+	result.Steps, err = c.executePlan(ctx, plan, handler)
+	if err != nil {
+		return result, err
+	}
+
+	// 3. 汇总最终回答：开启汇总时交给模型生成，否则以计划中最后一个成功步骤的结果作为最终结果
+	if c.cfg.Synthesize {
+		answer, err := c.synthesize(ctx, userQuery, result.Steps, &result.Usage, handler)
+		if err != nil {
+			return result, fmt.Errorf("汇总回答失败: %v", err)
+		}
+		result.Answer = answer
+		return result, nil
+	}
+	for i := len(result.Steps) - 1; i >= 0; i-- {
+		if result.Steps[i].Status == StepSucceeded {
+			result.Answer = result.Steps[i].Output
+			break
+		}
+	}
+	return result, nil
+}
+
+// planFormatPrompt 告知模型调用计划的JSON结构以及步骤间结果引用的写法
+const planFormatPrompt = `计划格式：{"steps": [{"tool_name": "工具名", "params": {"参数名": "参数值"}, "reason": "调用原因"}]}
+后续步骤的参数可以引用前面步骤的结果：{{steps[i].result}} 表示第i个步骤（从0开始）的完整结果，
+结果为JSON时可继续取字段或下标，如 {{steps[0].result.url}}、{{steps[0].result[1].name}}（对数组取字段时默认取第一个元素）。
+互不依赖的步骤会并发执行；如果某步骤必须在其他步骤之后执行，请在 "depends_on" 中列出这些步骤的下标，如 "depends_on": [0]。
+非关键步骤可设置 "continue_on_error": true，其失败不会中止整个计划。`
+
 // generateToolCallPlan 调用大模型生成工具调用计划
-func (c *Coordinator) generateToolCallPlan(userQuery string) (*ToolCallPlan, error) {
+// history为同一会话之前的问答，使模型能理解"打开第二个"之类的追问
+func (c *Coordinator) generateToolCallPlan(ctx context.Context, userQuery string, history []Turn, usage *TokenUsage) (*ToolCallPlan, error) {
 	// 构造模型输入（包含工具列表描述，让模型知道可用工具）
 	messages := []*schema.Message{
 		{
 			Role:    schema.System,
 			Content: c.buildToolDescriptionPrompt(), // 工具描述提示词（告知模型可用工具的功能和参数）
 		},
-		{
-			Role:    schema.User,
-			Content: fmt.Sprintf("用户查询：%s\n请生成一个工具调用计划（JSON格式），描述需要调用哪些工具、参数及原因。", userQuery),
-		},
 	}
+	messages = append(messages, historyMessages(history)...)
+	messages = append(messages, &schema.Message{
+		Role:    schema.User,
+		Content: fmt.Sprintf("用户查询：%s\n请生成一个工具调用计划（JSON格式），描述需要调用哪些工具、参数及原因。\n%s", userQuery, planFormatPrompt),
+	})
 
-	// 调用大模型生成工具调用计划
-	resp, err := c.model.Generate(c.ctx, messages)
-	if err != nil {
-		return nil, fmt.Errorf("模型调用失败: %v", err)
+	// 端点支持时要求模型直接输出JSON对象
+	var opts []model.Option
+	if c.cfg.JSONMode {
+		opts = append(opts, acl.WithExtraFields(map[string]any{
+			"response_format": map[string]any{"type": "json_object"},
+		}))
 	}
 
-	// 解析模型输出（假设模型返回JSON格式的工具调用计划）
-	if resp == nil || resp.Content == "" {
-		return nil, fmt.Errorf("模型返回内容为空")
-	}
-	plan := &ToolCallPlan{}
-	if err := json.Unmarshal([]byte(resp.Content), plan); err != nil {
-		return nil, fmt.Errorf("解析工具调用计划失败: %v\n模型输出: %s", err, resp.Content)
-	}
+	for attempt := 0; ; attempt++ {
+		// 调用大模型生成工具调用计划
+		resp, err := c.model.Generate(ctx, messages, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("模型调用失败: %v", err)
+		}
+		usage.add(resp)
 
-	return plan, nil
+		// 解析模型输出（兼容代码块包裹、前后夹杂说明文字等情况），失败时附上错误让模型重新输出
+		if resp == nil || resp.Content == "" {
+			return nil, fmt.Errorf("模型返回内容为空")
+		}
+		plan, err := parsePlan(resp.Content)
+		if err != nil {
+			if attempt >= c.cfg.MaxRepairAttempts {
+				return nil, fmt.Errorf("解析工具调用计划失败: %v\n模型输出: %s", err, resp.Content)
+			}
+			messages = append(messages, resp, schema.UserMessage(fmt.Sprintf(
+				"上述输出无法解析为计划JSON：%v\n请只输出符合计划格式的JSON，不要包含其他文字。", err)))
+			continue
+		}
+
+		// 执行前按工具输入schema校验，失败时把问题交给模型修正
+		problems := c.validatePlan(plan)
+		if len(problems) == 0 {
+			return plan, nil
+		}
+		if attempt >= c.cfg.MaxRepairAttempts {
+			return nil, fmt.Errorf("计划校验失败（已修正%d次）: %s", attempt, strings.Join(problems, "; "))
+		}
+		messages = append(messages, resp, schema.UserMessage(fmt.Sprintf(
+			"上述计划未通过校验：\n- %s\n请修正这些问题后重新输出完整的计划JSON。", strings.Join(problems, "\n- "))))
+	}
 }
 
 // buildToolDescriptionPrompt 构造工具描述提示词（告知模型可用工具的功能和参数要求）
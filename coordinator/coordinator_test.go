@@ -0,0 +1,243 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"mcp-server/internal/tool"
+	"mcp-server/internal/tool/tooltest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeReply 假模型的一次回复：ToolCalls非空时为函数调用
+type fakeReply struct {
+	Content   string
+	ToolCalls []fakeToolCall
+}
+
+// fakeToolCall 假模型发起的函数调用
+type fakeToolCall struct {
+	Name      string
+	Arguments string
+}
+
+// fakeChatRequest 假端点收到的请求中测试关心的字段
+type fakeChatRequest struct {
+	Stream   bool `json:"stream"`
+	Messages []struct {
+		Role       string `json:"role"`
+		Content    string `json:"content"`
+		ToolCallID string `json:"tool_call_id"`
+	} `json:"messages"`
+	Tools []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools"`
+}
+
+// fakeChat 按脚本回复的OpenAI兼容端点，支持流式输出与函数调用，并记录收到的请求
+type fakeChat struct {
+	replies  []fakeReply
+	requests []fakeChatRequest
+	mu       sync.Mutex
+}
+
+// newScriptedChatModel 创建指向本地假端点的聊天模型，依次以replies作为模型回复，回复用完后返回错误
+func newScriptedChatModel(t *testing.T, replies ...fakeReply) (*openai.ChatModel, *fakeChat) {
+	t.Helper()
+	fake := &fakeChat{replies: replies}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	chatModel, err := openai.NewChatModel(context.Background(), &openai.ChatModelConfig{
+		APIKey:  "test",
+		BaseURL: srv.URL,
+		Model:   "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chatModel, fake
+}
+
+// newFakeChatModel 创建依次以replies文本作为回复的聊天模型
+func newFakeChatModel(t *testing.T, replies ...string) *openai.ChatModel {
+	t.Helper()
+	scripted := make([]fakeReply, len(replies))
+	for i, reply := range replies {
+		scripted[i] = fakeReply{Content: reply}
+	}
+	chatModel, _ := newScriptedChatModel(t, scripted...)
+	return chatModel
+}
+
+// calls 返回收到的请求
+func (f *fakeChat) calls() []fakeChatRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeChatRequest(nil), f.requests...)
+}
+
+// ServeHTTP 实现http.Handler接口
+func (f *fakeChat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req fakeChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	n := len(f.requests)
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+	if n >= len(f.replies) {
+		http.Error(w, "unexpected model call", http.StatusInternalServerError)
+		return
+	}
+	reply := f.replies[n]
+
+	toolCalls := make([]map[string]any, len(reply.ToolCalls))
+	for i, call := range reply.ToolCalls {
+		toolCalls[i] = map[string]any{
+			"index":    i,
+			"id":       fmt.Sprintf("call_%d_%d", n+1, i+1),
+			"type":     "function",
+			"function": map[string]any{"name": call.Name, "arguments": call.Arguments},
+		}
+	}
+	finishReason := "stop"
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+	usage := map[string]any{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2}
+
+	if !req.Stream {
+		message := map[string]any{"role": "assistant", "content": reply.Content}
+		if len(toolCalls) > 0 {
+			message["tool_calls"] = toolCalls
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion",
+			"model":   "test",
+			"choices": []map[string]any{{"index": 0, "finish_reason": finishReason, "message": message}},
+			"usage":   usage,
+		})
+		return
+	}
+
+	// 流式：文本按字符逐块输出，函数调用单独一块，结束原因与用量附在最后一块
+	var deltas []map[string]any
+	for _, r := range reply.Content {
+		deltas = append(deltas, map[string]any{"role": "assistant", "content": string(r)})
+	}
+	if len(toolCalls) > 0 || len(deltas) == 0 {
+		deltas = append(deltas, map[string]any{"role": "assistant", "tool_calls": toolCalls})
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	for i, delta := range deltas {
+		choice := map[string]any{"index": 0, "delta": delta}
+		chunk := map[string]any{"id": "chatcmpl-test", "object": "chat.completion.chunk", "model": "test", "choices": []map[string]any{choice}}
+		if i == len(deltas)-1 {
+			choice["finish_reason"] = finishReason
+			chunk["usage"] = usage
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// failingSessionStore 读取正常、保存总是失败的会话存储
+type failingSessionStore struct{}
+
+func (failingSessionStore) History(ctx context.Context, sessionID string) ([]Turn, error) {
+	return nil, nil
+}
+
+func (failingSessionStore) Append(ctx context.Context, sessionID string, turn Turn) error {
+	return errors.New("disk full")
+}
+
+func TestExecuteStreamSendsFinalAnswerWhenSessionAppendFails(t *testing.T) {
+	c := newTestCoordinator(t, &Config{Mode: ModePlan}, tooltest.Echo("echo"))
+	c.model = newFakeChatModel(t, `{"steps":[{"tool_name":"echo","params":{"query":"答案"}}]}`)
+	c.sessions = failingSessionStore{}
+
+	var final []string
+	result, err := c.ExecuteStream(context.Background(), "session-1", "问题", func(e Event) {
+		if e.Type == EventFinalAnswer {
+			final = append(final, e.Answer)
+		}
+	})
+	if err != nil {
+		t.Fatalf("ExecuteStream() error = %v", err)
+	}
+	if result.Answer != "答案" {
+		t.Errorf("Answer = %q, want %q", result.Answer, "答案")
+	}
+	if len(final) != 1 || final[0] != "答案" {
+		t.Errorf("final_answer events = %q, want one with %q", final, "答案")
+	}
+}
+
+func TestExecuteStreamPassesSessionAsClientID(t *testing.T) {
+	c := newTestCoordinator(t, &Config{Mode: ModePlan}, tooltest.Echo("echo"))
+	plan := `{"steps":[{"tool_name":"echo","params":{"query":"答案"}}]}`
+	c.model = newFakeChatModel(t, plan, plan, plan)
+	limiter, err := tool.NewLimiter(tool.MiddlewareConfig{Limit: tool.LimitConfig{PerClientRate: 0.001, PerClientBurst: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.toolManager.Use(limiter.Middleware)
+
+	// 同一会话的第二次查询超出该客户端的速率限制，其他会话不受影响
+	if _, err := c.Execute(context.Background(), "session-1", "问题"); err != nil {
+		t.Fatalf("first query error = %v", err)
+	}
+	if _, err := c.Execute(context.Background(), "session-1", "问题"); err == nil || !strings.Contains(err.Error(), "当前客户端") {
+		t.Errorf("second query from session-1 error = %v, want client rate limit", err)
+	}
+	if _, err := c.Execute(context.Background(), "session-2", "问题"); err != nil {
+		t.Errorf("query from session-2 error = %v", err)
+	}
+}
+
+func TestExecuteStreamStopsWhenContextCancelled(t *testing.T) {
+	started := make(chan struct{})
+	blocking := tooltest.New("blocking", func(ctx context.Context, args map[string]interface{}) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	c := newTestCoordinator(t, &Config{Mode: ModePlan}, blocking)
+	c.model = newFakeChatModel(t, `{"steps":[{"tool_name":"blocking"}]}`)
+
+	// 模拟客户端断开：工具开始执行后取消请求上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.ExecuteStream(ctx, "", "问题", nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("ExecuteStream() error = nil, want cancellation")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ExecuteStream() did not return after the context was cancelled")
+	}
+}
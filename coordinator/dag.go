@@ -0,0 +1,224 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxConcurrency 计划模式下默认的最大并发步骤数
+const defaultMaxConcurrency = 4
+
+// stepDone 单个步骤的执行结果（由执行协程回传给调度循环）
+type stepDone struct {
+	index    int
+	params   map[string]interface{}
+	outcome  callOutcome
+	duration time.Duration
+	err      error
+}
+
+// planDependencies 计算每个步骤的依赖（显式的depends_on与参数中的结果引用），并检测循环依赖
+func planDependencies(plan *ToolCallPlan) ([][]int, error) {
+	n := len(plan.Steps)
+	deps := make([][]int, n)
+	for i, step := range plan.Steps {
+		set := make(map[int]bool)
+		for _, d := range step.DependsOn {
+			set[d] = true
+		}
+		collectReferences(step.Params, set)
+
+		for d := range set {
+			if d < 0 || d >= n {
+				return nil, fmt.Errorf("步骤%d: 依赖的步骤下标 %d 不存在", i+1, d)
+			}
+			if d == i {
+				return nil, fmt.Errorf("步骤%d: 不能依赖自身", i+1)
+			}
+			deps[i] = append(deps[i], d)
+		}
+		sort.Ints(deps[i])
+	}
+
+	// Kahn算法检测循环依赖
+	remaining := make([]int, n)
+	dependents := make([][]int, n)
+	for i, d := range deps {
+		remaining[i] = len(d)
+		for _, j := range d {
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+	queue := make([]int, 0, n)
+	for i := range remaining {
+		if remaining[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, k := range dependents[i] {
+			remaining[k]--
+			if remaining[k] == 0 {
+				queue = append(queue, k)
+			}
+		}
+	}
+	if visited < n {
+		var cyclic []string
+		for i := range remaining {
+			if remaining[i] > 0 {
+				cyclic = append(cyclic, fmt.Sprintf("步骤%d", i+1))
+			}
+		}
+		return nil, fmt.Errorf("步骤间存在循环依赖: %s", strings.Join(cyclic, ", "))
+	}
+	return deps, nil
+}
+
+// collectReferences 收集参数中引用到的步骤下标
+func collectReferences(value any, set map[int]bool) {
+	switch v := value.(type) {
+	case string:
+		for _, m := range referencePattern.FindAllStringSubmatch(v, -1) {
+			if i, err := strconv.Atoi(m[1]); err == nil {
+				set[i] = true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			collectReferences(item, set)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectReferences(item, set)
+		}
+	}
+}
+
+// executePlan 按依赖关系并发执行计划中的步骤（最多MaxConcurrency个同时运行）
+// 任一步骤失败后取消其余正在执行的步骤，且不再启动新的步骤（标记了continue_on_error的步骤失败不影响其他步骤）；
+// 无论成功与否都返回每个步骤的执行记录，未执行的步骤标记为skipped
+func (c *Coordinator) executePlan(ctx context.Context, plan *ToolCallPlan, handler EventHandler) ([]StepResult, error) {
+	n := len(plan.Steps)
+	steps := make([]StepResult, n)
+	for i, step := range plan.Steps {
+		steps[i] = StepResult{
+			Step:     i + 1,
+			ToolName: step.ToolName,
+			Params:   step.Params,
+			Reason:   step.Reason,
+			Status:   StepSkipped,
+		}
+	}
+
+	deps, err := planDependencies(plan)
+	if err != nil {
+		return steps, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	remaining := make([]int, n)
+	dependents := make([][]int, n)
+	ready := make([]int, 0, n)
+	for i, d := range deps {
+		remaining[i] = len(d)
+		for _, j := range d {
+			dependents[j] = append(dependents[j], i)
+		}
+		if len(d) == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	// 调度循环独占outputs与steps，执行协程只通过done回传结果
+	outputs := make([]*stepOutput, n)
+	done := make(chan stepDone)
+	running := 0
+	var firstErr error
+
+	// complete 记录步骤结束：硬失败时取消整个计划，否则释放依赖它的步骤
+	// 软失败（continue_on_error）的步骤没有输出，引用其结果的后续步骤会在解析参数时失败
+	complete := func(i int, err error) {
+		if err != nil {
+			steps[i].Status = StepFailed
+			steps[i].Error = err.Error()
+		}
+		finished := steps[i]
+		handler.emit(Event{Type: EventStepFinished, Step: &finished})
+
+		if err != nil && !plan.Steps[i].ContinueOnError {
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			return
+		}
+		for _, k := range dependents[i] {
+			remaining[k]--
+			if remaining[k] == 0 {
+				ready = append(ready, k)
+			}
+		}
+	}
+
+	for {
+		for firstErr == nil && len(ready) > 0 && running < c.cfg.MaxConcurrency {
+			i := ready[0]
+			ready = ready[1:]
+
+			params, err := resolveReferences(plan.Steps[i].Params, outputs)
+			if err != nil {
+				complete(i, fmt.Errorf("步骤%d: 参数引用解析失败: %v", i+1, err))
+				continue
+			}
+
+			running++
+			started := steps[i]
+			started.Params = params
+			handler.emit(Event{Type: EventStepStarted, Step: &started})
+			go func(i int, params map[string]interface{}) {
+				start := time.Now()
+				outcome, err := c.executeStep(ctx, i, plan.Steps[i], params)
+				done <- stepDone{index: i, params: params, outcome: outcome, duration: time.Since(start), err: err}
+			}(i, params)
+		}
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+		steps[d.index].Params = d.params
+		steps[d.index].DurationMs = d.duration.Milliseconds()
+		steps[d.index].Attempts = d.outcome.Attempts
+		steps[d.index].Fallback = d.outcome.Fallback
+		if d.err == nil {
+			output := newStepOutput(d.outcome.Text)
+			outputs[d.index] = &output
+			steps[d.index].Status = StepSucceeded
+			steps[d.index].Output = output.Text
+		}
+		complete(d.index, d.err)
+	}
+
+	return steps, firstErr
+}
+
+// executeStep 按工具策略执行单个计划步骤
+func (c *Coordinator) executeStep(ctx context.Context, index int, step ToolCallStep, params map[string]interface{}) (callOutcome, error) {
+	outcome, err := c.callWithPolicy(ctx, step.ToolName, params)
+	if err != nil {
+		return outcome, fmt.Errorf("步骤%d: 工具 %s 执行失败: %v", index+1, step.ToolName, err)
+	}
+	return outcome, nil
+}
@@ -0,0 +1,239 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcp-server/internal/tool"
+	"mcp-server/internal/tool/tooltest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestCoordinator 创建只包含给定工具的协调器
+func newTestCoordinator(t *testing.T, cfg *Config, tools ...*tooltest.Tool) *Coordinator {
+	t.Helper()
+	manager := tool.NewToolManager(tool.Dependencies{})
+	cfgs := make(map[string]any, len(tools))
+	for _, ft := range tools {
+		if err := manager.Register(ft.ToolName, func(context.Context, any, tool.Dependencies) (tool.Tool, error) {
+			return ft, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		cfgs[ft.ToolName] = struct{}{}
+	}
+	if err := manager.InitTools(context.Background(), cfgs); err != nil {
+		t.Fatal(err)
+	}
+	return NewCoordinator(nil, manager, cfg)
+}
+
+func TestPlanDependencies(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []ToolCallStep
+		want    [][]int
+		wantErr string
+	}{
+		{
+			name:  "无依赖",
+			steps: []ToolCallStep{{ToolName: "a"}, {ToolName: "b"}},
+			want:  [][]int{nil, nil},
+		},
+		{
+			name: "显式依赖与引用合并去重",
+			steps: []ToolCallStep{
+				{ToolName: "a"},
+				{ToolName: "b"},
+				{ToolName: "c", DependsOn: []int{1}, Params: map[string]interface{}{
+					"q":    "{{steps[0].result}} {{steps[1].result.url}}",
+					"list": []interface{}{map[string]interface{}{"x": "{{steps[0].result}}"}},
+				}},
+			},
+			want: [][]int{nil, nil, {0, 1}},
+		},
+		{
+			name:    "依赖不存在的步骤",
+			steps:   []ToolCallStep{{ToolName: "a", DependsOn: []int{3}}},
+			wantErr: "依赖的步骤下标 3 不存在",
+		},
+		{
+			name:    "依赖自身",
+			steps:   []ToolCallStep{{ToolName: "a", Params: map[string]interface{}{"q": "{{steps[0].result}}"}}},
+			wantErr: "不能依赖自身",
+		},
+		{
+			name: "循环依赖",
+			steps: []ToolCallStep{
+				{ToolName: "a"},
+				{ToolName: "b", DependsOn: []int{2}},
+				{ToolName: "c", DependsOn: []int{1}},
+			},
+			wantErr: "循环依赖: 步骤2, 步骤3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := planDependencies(&ToolCallPlan{Steps: tt.steps})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("planDependencies() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planDependencies() error = %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("planDependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutePlanResolvesReferencesInDependencyOrder(t *testing.T) {
+	c := newTestCoordinator(t, &Config{Mode: ModePlan}, tooltest.Echo("echo"))
+	plan := &ToolCallPlan{Steps: []ToolCallStep{
+		{ToolName: "echo", Params: map[string]interface{}{"query": "{{steps[1].result}}-c"}},
+		{ToolName: "echo", Params: map[string]interface{}{"query": "{{steps[2].result}}-b"}},
+		{ToolName: "echo", Params: map[string]interface{}{"query": "a"}},
+	}}
+
+	var mu sync.Mutex
+	var events []string
+	steps, err := c.executePlan(context.Background(), plan, func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf("%s:%d", e.Type, e.Step.Step))
+	})
+	if err != nil {
+		t.Fatalf("executePlan() error = %v", err)
+	}
+	if got := steps[0].Output; got != "a-b-c" {
+		t.Errorf("steps[0].Output = %q, want %q", got, "a-b-c")
+	}
+	want := "step_started:3 step_finished:3 step_started:2 step_finished:2 step_started:1 step_finished:1"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	for _, step := range steps {
+		if step.Status != StepSucceeded || step.Attempts != 1 {
+			t.Errorf("step %d: status = %s, attempts = %d", step.Step, step.Status, step.Attempts)
+		}
+	}
+}
+
+func TestExecutePlanRespectsMaxConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	slow := tooltest.New("slow", func(ctx context.Context, args map[string]interface{}) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return "ok", nil
+	})
+	c := newTestCoordinator(t, &Config{Mode: ModePlan, MaxConcurrency: 3}, slow)
+
+	plan := &ToolCallPlan{}
+	for i := 0; i < 8; i++ {
+		plan.Steps = append(plan.Steps, ToolCallStep{ToolName: "slow"})
+	}
+	steps, err := c.executePlan(context.Background(), plan, nil)
+	if err != nil {
+		t.Fatalf("executePlan() error = %v", err)
+	}
+	if got := peak.Load(); got != 3 {
+		t.Errorf("peak concurrency = %d, want 3", got)
+	}
+	for _, step := range steps {
+		if step.Status != StepSucceeded {
+			t.Errorf("step %d: status = %s", step.Step, step.Status)
+		}
+	}
+}
+
+func TestExecutePlanFailureCancelsRunningSteps(t *testing.T) {
+	started := make(chan struct{})
+	blocking := tooltest.New("blocking", func(ctx context.Context, args map[string]interface{}) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	failing := tooltest.New("failing", func(ctx context.Context, args map[string]interface{}) (string, error) {
+		<-started
+		return "", errors.New("boom")
+	})
+	c := newTestCoordinator(t, &Config{Mode: ModePlan}, blocking, failing, tooltest.Echo("echo"))
+
+	plan := &ToolCallPlan{Steps: []ToolCallStep{
+		{ToolName: "blocking"},
+		{ToolName: "failing"},
+		{ToolName: "echo", DependsOn: []int{1}},
+	}}
+	steps, err := c.executePlan(context.Background(), plan, nil)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("executePlan() error = %v, want boom", err)
+	}
+	want := []string{StepFailed, StepFailed, StepSkipped}
+	for i, step := range steps {
+		if step.Status != want[i] {
+			t.Errorf("step %d: status = %s, want %s", step.Step, step.Status, want[i])
+		}
+	}
+	if !strings.Contains(steps[0].Error, context.Canceled.Error()) {
+		t.Errorf("step 1 error = %q, want cancellation", steps[0].Error)
+	}
+}
+
+func TestExecutePlanContinueOnError(t *testing.T) {
+	failing := tooltest.New("failing", func(ctx context.Context, args map[string]interface{}) (string, error) {
+		return "", errors.New("boom")
+	})
+	c := newTestCoordinator(t, &Config{Mode: ModePlan}, failing, tooltest.Echo("echo"))
+
+	plan := &ToolCallPlan{Steps: []ToolCallStep{
+		{ToolName: "failing", ContinueOnError: true},
+		{ToolName: "echo", DependsOn: []int{0}, Params: map[string]interface{}{"query": "after"}},
+		{ToolName: "echo", Params: map[string]interface{}{"query": "{{steps[0].result}}"}, ContinueOnError: true},
+	}}
+	steps, err := c.executePlan(context.Background(), plan, nil)
+	if err != nil {
+		t.Fatalf("executePlan() error = %v", err)
+	}
+	if steps[0].Status != StepFailed {
+		t.Errorf("step 1: status = %s, want failed", steps[0].Status)
+	}
+	if steps[1].Status != StepSucceeded || steps[1].Output != "after" {
+		t.Errorf("step 2: status = %s, output = %q", steps[1].Status, steps[1].Output)
+	}
+	// 软失败的步骤没有输出，引用其结果的步骤在解析参数时失败
+	if steps[2].Status != StepFailed || !strings.Contains(steps[2].Error, "尚未执行") {
+		t.Errorf("step 3: status = %s, error = %q", steps[2].Status, steps[2].Error)
+	}
+}
+
+func TestExecutePlanRejectsCycles(t *testing.T) {
+	c := newTestCoordinator(t, &Config{Mode: ModePlan}, tooltest.Echo("echo"))
+	plan := &ToolCallPlan{Steps: []ToolCallStep{
+		{ToolName: "echo", DependsOn: []int{1}},
+		{ToolName: "echo", DependsOn: []int{0}},
+	}}
+	steps, err := c.executePlan(context.Background(), plan, nil)
+	if err == nil {
+		t.Fatal("executePlan() error = nil, want cycle error")
+	}
+	for _, step := range steps {
+		if step.Status != StepSkipped {
+			t.Errorf("step %d: status = %s, want skipped", step.Step, step.Status)
+		}
+	}
+}
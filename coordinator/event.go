@@ -0,0 +1,69 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"io"
+)
+
+// 执行过程事件类型
+const (
+	EventPlanGenerated = "plan_generated" // 调用计划已生成（仅计划模式）
+	EventStepStarted   = "step_started"   // 步骤开始执行
+	EventStepFinished  = "step_finished"  // 步骤执行结束（成功或失败）
+	EventToken         = "token"          // 模型输出的增量文本
+	EventFinalAnswer   = "final_answer"   // 最终回答
+)
+
+// Event 协调器执行过程中产生的进度事件
+type Event struct {
+	Type   string        `json:"type"`             // 事件类型
+	Plan   *ToolCallPlan `json:"plan,omitempty"`   // plan_generated 时的调用计划
+	Step   *StepResult   `json:"step,omitempty"`   // step_started / step_finished 时的步骤记录
+	Delta  string        `json:"delta,omitempty"`  // token 时的增量文本
+	Answer string        `json:"answer,omitempty"` // final_answer 时的最终回答
+}
+
+// EventHandler 进度事件回调，事件按发生顺序在同一协程中依次回调
+type EventHandler func(Event)
+
+// emit 推送事件（未设置回调时忽略）
+func (h EventHandler) emit(event Event) {
+	if h != nil {
+		h(event)
+	}
+}
+
+// generate 调用模型；设置了事件回调时以流式方式调用，并将增量文本作为token事件推送
+func (c *Coordinator) generate(ctx context.Context, messages []*schema.Message, handler EventHandler, opts ...model.Option) (*schema.Message, error) {
+	if handler == nil {
+		return c.model.Generate(ctx, messages, opts...)
+	}
+
+	stream, err := c.model.Stream(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var chunks []*schema.Message
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunk.Content != "" {
+			handler.emit(Event{Type: EventToken, Delta: chunk.Content})
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	return schema.ConcatMessages(chunks)
+}
@@ -0,0 +1,135 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// codeFencePattern 匹配markdown代码块（```json ... ```）
+var codeFencePattern = regexp.MustCompile("(?s)```[A-Za-z]*\\s*\\n?(.*?)```")
+
+// parsePlan 宽松地从模型输出中解析调用计划：
+// 去除markdown代码块，从前往后依次尝试每个以 { 或 [ 开始、括号配平的合法JSON片段，
+// 跳过说明文字中的 [1]、{备注} 等括号，并兼容直接返回步骤数组或单个步骤的写法
+func parsePlan(content string) (*ToolCallPlan, error) {
+	text := strings.TrimSpace(content)
+	if m := codeFencePattern.FindStringSubmatch(text); m != nil {
+		text = strings.TrimSpace(m[1])
+	}
+
+	// 都不能解析时报告位置最靠前的错误
+	var firstErr error
+	for start := 0; start < len(text); start++ {
+		if (text[start] != '{' && text[start] != '[') || nestedInJSON(text, start) {
+			continue
+		}
+		raw, err := extractJSON(text, start)
+		if err == nil {
+			var plan *ToolCallPlan
+			if plan, err = decodePlan(raw); err == nil {
+				return plan, nil
+			}
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		return nil, fmt.Errorf("输出中未找到JSON")
+	}
+	return nil, firstErr
+}
+
+// nestedInJSON 判断start处的括号是否为JSON结构内部的值（紧跟在 [ 、逗号或 "键": 之后）
+// 这样的片段属于外层JSON的一部分，外层不完整时不应单独当作计划（避免截断的输出被解析为部分计划）
+func nestedInJSON(text string, start int) bool {
+	prev := strings.TrimRight(text[:start], " \t\r\n")
+	if prev == "" {
+		return false
+	}
+	switch prev[len(prev)-1] {
+	case '[', ',':
+		return true
+	case ':':
+		return strings.HasSuffix(strings.TrimRight(prev[:len(prev)-1], " \t\r\n"), `"`)
+	}
+	return false
+}
+
+// decodePlan 将一段合法的JSON解码为调用计划
+func decodePlan(raw string) (*ToolCallPlan, error) {
+	// 直接返回步骤数组
+	if raw[0] == '[' {
+		var steps []ToolCallStep
+		if err := json.Unmarshal([]byte(raw), &steps); err != nil {
+			return nil, fmt.Errorf("解析步骤数组失败: %v", err)
+		}
+		return &ToolCallPlan{Steps: steps}, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return nil, fmt.Errorf("解析计划对象失败: %v", err)
+	}
+	// 只返回了单个步骤
+	if _, ok := fields["steps"]; !ok {
+		if _, ok := fields["tool_name"]; ok {
+			var step ToolCallStep
+			if err := json.Unmarshal([]byte(raw), &step); err != nil {
+				return nil, fmt.Errorf("解析单个步骤失败: %v", err)
+			}
+			return &ToolCallPlan{Steps: []ToolCallStep{step}}, nil
+		}
+		return nil, fmt.Errorf("计划对象中缺少steps字段")
+	}
+
+	plan := &ToolCallPlan{}
+	if err := json.Unmarshal([]byte(raw), plan); err != nil {
+		return nil, fmt.Errorf("解析计划对象失败: %v", err)
+	}
+	return plan, nil
+}
+
+// extractJSON 返回从start处的括号开始、括号配平的合法JSON片段（忽略字符串内的括号）
+func extractJSON(text string, start int) (string, error) {
+	var stack []byte
+	inString, escaped := false, false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != ch {
+				return "", fmt.Errorf("JSON括号不匹配（位置%d）", i)
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				raw := text[start : i+1]
+				if !json.Valid([]byte(raw)) {
+					return "", fmt.Errorf("位置%d处的内容不是合法的JSON", start)
+				}
+				return raw, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("JSON不完整，缺少结束括号")
+}
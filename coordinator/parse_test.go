@@ -0,0 +1,107 @@
+package coordinator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantTools []string
+		wantErr   string
+	}{
+		{
+			name:      "标准计划",
+			content:   `{"steps":[{"tool_name":"web_search","params":{"query":"北京"}}]}`,
+			wantTools: []string{"web_search"},
+		},
+		{
+			name:      "markdown代码块",
+			content:   "计划如下：\n```json\n{\"steps\":[{\"tool_name\":\"a\"},{\"tool_name\":\"b\"}]}\n```\n",
+			wantTools: []string{"a", "b"},
+		},
+		{
+			name:      "直接返回步骤数组",
+			content:   `[{"tool_name":"a"},{"tool_name":"b"}]`,
+			wantTools: []string{"a", "b"},
+		},
+		{
+			name:      "只返回单个步骤",
+			content:   `好的：{"tool_name":"a","params":{}}`,
+			wantTools: []string{"a"},
+		},
+		{
+			name:      "字符串中的括号",
+			content:   `{"steps":[{"tool_name":"a","params":{"query":"[x] {y} \"}\""}}]}`,
+			wantTools: []string{"a"},
+		},
+		{
+			name:      "前文包含合法JSON数组引用",
+			content:   `参考资料 see [1]，计划：{"steps":[{"tool_name":"a"}]}`,
+			wantTools: []string{"a"},
+		},
+		{
+			name:      "前文包含非法JSON的花括号",
+			content:   `{note} 先搜索再打开：{"steps":[{"tool_name":"a"},{"tool_name":"b","depends_on":[0]}]}`,
+			wantTools: []string{"a", "b"},
+		},
+		{
+			name:      "前文括号不匹配",
+			content:   `步骤[1} 如下 [{"tool_name":"a"}]`,
+			wantTools: []string{"a"},
+		},
+		{
+			name:      "前文未闭合的括号",
+			content:   `（可选[ 参数）{"steps":[{"tool_name":"a"}]}`,
+			wantTools: []string{"a"},
+		},
+		{
+			name:    "没有JSON",
+			content: `无法生成计划`,
+			wantErr: "未找到JSON",
+		},
+		{
+			name:    "JSON不完整",
+			content: `{"steps":[{"tool_name":"a"}`,
+			wantErr: "缺少结束括号",
+		},
+		{
+			name:    "截断的计划不会被解析为其中的单个步骤",
+			content: `计划：{"steps":[{"tool_name":"a","params":{"q":"{{steps[0].result}}"}}, {"tool_name":"b"`,
+			wantErr: "缺少结束括号",
+		},
+		{
+			name:    "缺少steps字段",
+			content: `{"plan":"none"}`,
+			wantErr: "缺少steps字段",
+		},
+		{
+			name:    "只有不能解析为计划的JSON时报告第一个错误",
+			content: `see [1] and [2]`,
+			wantErr: "解析步骤数组失败",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := parsePlan(tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parsePlan() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePlan() error = %v", err)
+			}
+			var got []string
+			for _, step := range plan.Steps {
+				got = append(got, step.ToolName)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantTools, ",") {
+				t.Errorf("parsePlan() tools = %v, want %v", got, tt.wantTools)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mark3labs/mcp-go/mcp"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBackoff    = 500 * time.Millisecond // 默认首次重试等待时间
+	defaultMaxBackoff = 10 * time.Second       // 默认重试等待上限
+)
+
+// Policy 单个工具的调用策略
+// 每次调用（含每次重试）的超时由工具调用中间件统一控制（middleware.timeout / middleware.tools.<name>.timeout），对MCP客户端和协调器同样生效
+type Policy struct {
+	MaxRetries      int    `json:"max_retries" jsonschema:"minimum=0"`       // 失败后的最大重试次数
+	Backoff         string `json:"backoff" jsonschema:"format=duration"`     // 首次重试前的等待时间，之后按指数增长并加随机抖动（默认"500ms"）
+	MaxBackoff      string `json:"max_backoff" jsonschema:"format=duration"` // 重试等待时间上限（默认"10s"）
+	Fallback        string `json:"fallback"`                                 // 重试后仍失败时改用的后备工具（使用相同参数）
+	FallbackOnEmpty bool   `json:"fallback_on_empty"`                        // 结果为空时也改用后备工具
+}
+
+// policy 解析后的调用策略
+type policy struct {
+	maxRetries      int
+	backoff         time.Duration
+	maxBackoff      time.Duration
+	fallback        string
+	fallbackOnEmpty bool
+}
+
+// callOutcome 一次带策略的工具调用结果
+type callOutcome struct {
+	Text     string // 工具返回文本
+	Attempts int    // 实际调用次数（含重试及后备工具）
+	Fallback string // 使用了后备工具时为后备工具名
+}
+
+// parsePolicies 解析配置中的工具策略，非法的时长按默认值处理
+func parsePolicies(cfgs map[string]Policy) map[string]policy {
+	policies := make(map[string]policy, len(cfgs))
+	for name, cfg := range cfgs {
+		policies[name] = policy{
+			maxRetries:      max(cfg.MaxRetries, 0),
+			backoff:         parseDuration(cfg.Backoff, defaultBackoff),
+			maxBackoff:      parseDuration(cfg.MaxBackoff, defaultMaxBackoff),
+			fallback:        cfg.Fallback,
+			fallbackOnEmpty: cfg.FallbackOnEmpty,
+		}
+	}
+	return policies
+}
+
+// parseDuration 解析时长字符串，为空或解析失败时返回默认值
+func parseDuration(s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// policyFor 返回工具的调用策略，未配置时不重试、无后备
+func (c *Coordinator) policyFor(toolName string) policy {
+	if p, ok := c.policies[toolName]; ok {
+		return p
+	}
+	return policy{backoff: defaultBackoff, maxBackoff: defaultMaxBackoff}
+}
+
+// callWithPolicy 按工具策略调用工具：指数退避重试，失败（或按配置结果为空）时改用后备工具
+func (c *Coordinator) callWithPolicy(ctx context.Context, toolName string, params map[string]interface{}) (callOutcome, error) {
+	outcome, err := c.callWithRetry(ctx, toolName, params)
+
+	p := c.policyFor(toolName)
+	if p.fallback == "" || p.fallback == toolName || ctx.Err() != nil {
+		return outcome, err
+	}
+	if err == nil && !(p.fallbackOnEmpty && isEmptyOutput(outcome.Text)) {
+		return outcome, nil
+	}
+
+	fallback, fbErr := c.callWithRetry(ctx, p.fallback, params)
+	fallback.Attempts += outcome.Attempts
+	fallback.Fallback = p.fallback
+	if fbErr != nil {
+		if err != nil {
+			return fallback, fmt.Errorf("%v；后备工具 %s 也执行失败: %v", err, p.fallback, fbErr)
+		}
+		// 原工具成功但结果为空，后备失败时仍使用原结果
+		return outcome, nil
+	}
+	return fallback, nil
+}
+
+// callWithRetry 按策略的重试次数调用单个工具
+func (c *Coordinator) callWithRetry(ctx context.Context, toolName string, params map[string]interface{}) (callOutcome, error) {
+	var outcome callOutcome
+	if _, ok := c.lookupTool(toolName); !ok {
+		return outcome, fmt.Errorf("工具 %s 不存在", toolName)
+	}
+
+	p := c.policyFor(toolName)
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Name:      toolName,
+			Arguments: params,
+		},
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(backoffDelay(p, attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return outcome, fmt.Errorf("%v（重试被取消: %v）", lastErr, ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		outcome.Attempts++
+		result, err := c.toolManager.Call(ctx, toolName, req)
+
+		switch {
+		case err != nil:
+			lastErr = err
+		case result == nil:
+			return outcome, nil
+		case result.IsError:
+			lastErr = errors.New(resultText(result))
+		default:
+			outcome.Text = resultText(result)
+			return outcome, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if outcome.Attempts > 1 {
+		return outcome, fmt.Errorf("%v（共尝试%d次）", lastErr, outcome.Attempts)
+	}
+	return outcome, lastErr
+}
+
+// backoffDelay 计算第attempt次重试前的等待时间：指数增长、不超过上限，并在[d/2, d]之间随机抖动
+func backoffDelay(p policy, attempt int) time.Duration {
+	d := p.backoff << (attempt - 1)
+	if d <= 0 || d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	return d
+}
+
+// isEmptyOutput 判断工具结果是否为空（空文本、null、空数组或空对象）
+func isEmptyOutput(text string) bool {
+	switch strings.TrimSpace(text) {
+	case "", "null", "[]", "{}":
+		return true
+	}
+	return false
+}
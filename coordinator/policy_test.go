@@ -0,0 +1,188 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcp-server/internal/tool"
+	"mcp-server/internal/tool/tooltest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallWithPolicyRetriesAfterMiddlewareTimeout(t *testing.T) {
+	var calls atomic.Int32
+	slow := tooltest.New("slow", func(ctx context.Context, args map[string]interface{}) (string, error) {
+		// 第一次调用阻塞到超时，之后立即返回
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return "ok", nil
+	})
+	c := newTestCoordinator(t, &Config{Policies: map[string]Policy{"slow": {MaxRetries: 1, Backoff: "1ms"}}}, slow)
+	// 单次调用超时只由中间件的按工具配置决定
+	c.toolManager.Use(tool.Timeout(0, map[string]time.Duration{"slow": 20 * time.Millisecond}))
+
+	outcome, err := c.callWithPolicy(context.Background(), "slow", nil)
+	if err != nil {
+		t.Fatalf("callWithPolicy() error = %v", err)
+	}
+	if outcome.Text != "ok" || outcome.Attempts != 2 {
+		t.Errorf("callWithPolicy() = %+v, want ok after 2 attempts", outcome)
+	}
+}
+
+// scriptedTool 依次返回outputs中的结果，以"error:"开头的输出作为工具错误返回，用完后重复最后一个
+func scriptedTool(name string, outputs ...string) *tooltest.Tool {
+	var calls atomic.Int32
+	return tooltest.New(name, func(ctx context.Context, args map[string]interface{}) (string, error) {
+		i := min(int(calls.Add(1)), len(outputs)) - 1
+		if msg, ok := strings.CutPrefix(outputs[i], "error:"); ok {
+			return "", errors.New(msg)
+		}
+		return outputs[i], nil
+	})
+}
+
+func TestCallWithPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       Policy
+		primary      []string
+		backup       []string
+		tool         string // 为空时调用primary
+		wantText     string
+		wantAttempts int
+		wantFallback string
+		wantErr      string
+	}{
+		{name: "未配置策略时不重试", primary: []string{"error:down", "ok"}, wantAttempts: 1, wantErr: "down"},
+		{name: "重试后成功", policy: Policy{MaxRetries: 2}, primary: []string{"error:down", "error:down", "ok"}, wantText: "ok", wantAttempts: 3},
+		{name: "重试次数用尽", policy: Policy{MaxRetries: 2}, primary: []string{"error:down"}, wantAttempts: 3, wantErr: "down（共尝试3次）"},
+		{
+			name: "失败后改用后备工具", policy: Policy{MaxRetries: 1, Fallback: "backup"},
+			primary: []string{"error:down"}, backup: []string{"backup result"},
+			wantText: "backup result", wantAttempts: 3, wantFallback: "backup",
+		},
+		{
+			name: "后备工具也失败", policy: Policy{Fallback: "backup"},
+			primary: []string{"error:down"}, backup: []string{"error:also down"},
+			wantAttempts: 2, wantFallback: "backup", wantErr: "down；后备工具 backup 也执行失败: also down",
+		},
+		{
+			name: "结果为空时改用后备工具", policy: Policy{Fallback: "backup", FallbackOnEmpty: true},
+			primary: []string{"[]"}, backup: []string{"backup result"},
+			wantText: "backup result", wantAttempts: 2, wantFallback: "backup",
+		},
+		{
+			name: "未开启fallback_on_empty时接受空结果", policy: Policy{Fallback: "backup"},
+			primary: []string{" {} "}, backup: []string{"backup result"},
+			wantText: " {} ", wantAttempts: 1,
+		},
+		{
+			name: "结果为空且后备失败时使用原结果", policy: Policy{Fallback: "backup", FallbackOnEmpty: true},
+			primary: []string{"null"}, backup: []string{"error:also down"},
+			wantText: "null", wantAttempts: 1,
+		},
+		{name: "工具不存在时不重试", policy: Policy{MaxRetries: 3}, tool: "missing", wantAttempts: 0, wantErr: "工具 missing 不存在"},
+		{
+			name: "工具不存在时直接改用后备工具", policy: Policy{MaxRetries: 3, Fallback: "backup"},
+			tool: "missing", backup: []string{"backup result"},
+			wantText: "backup result", wantAttempts: 1, wantFallback: "backup",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.Backoff = "1ms"
+			toolName := tt.tool
+			if toolName == "" {
+				toolName = "primary"
+			}
+			tools := []*tooltest.Tool{scriptedTool("backup", append(tt.backup, "unused")...)}
+			if tt.primary != nil {
+				tools = append(tools, scriptedTool("primary", tt.primary...))
+			}
+			c := newTestCoordinator(t, &Config{Policies: map[string]Policy{toolName: tt.policy}}, tools...)
+
+			outcome, err := c.callWithPolicy(context.Background(), toolName, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if tt.wantErr == "" && outcome.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", outcome.Text, tt.wantText)
+			}
+			if outcome.Attempts != tt.wantAttempts || outcome.Fallback != tt.wantFallback {
+				t.Errorf("Attempts, Fallback = %d, %q, want %d, %q", outcome.Attempts, outcome.Fallback, tt.wantAttempts, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestCallWithPolicyStopsRetryingWhenCancelled(t *testing.T) {
+	failed := make(chan struct{}, 1)
+	primary := tooltest.New("primary", func(ctx context.Context, args map[string]interface{}) (string, error) {
+		failed <- struct{}{}
+		return "", errors.New("down")
+	})
+	backup := scriptedTool("backup", "backup result")
+	c := newTestCoordinator(t, &Config{Policies: map[string]Policy{"primary": {MaxRetries: 3, Backoff: "1m", Fallback: "backup"}}}, primary, backup)
+
+	// 首次失败后进入退避等待时取消
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-failed
+		cancel()
+	}()
+	start := time.Now()
+	outcome, err := c.callWithPolicy(ctx, "primary", nil)
+	if err == nil || !strings.Contains(err.Error(), "重试被取消") {
+		t.Errorf("error = %v, want retry cancelled", err)
+	}
+	if outcome.Attempts != 1 || outcome.Fallback != "" {
+		t.Errorf("outcome = %+v, want a single attempt without fallback", outcome)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("callWithPolicy took %v after cancellation", elapsed)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	p := policy{backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 2, min: 100 * time.Millisecond, max: 200 * time.Millisecond},
+		{attempt: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{attempt: 4, min: 400 * time.Millisecond, max: 800 * time.Millisecond},
+		{attempt: 5, min: 500 * time.Millisecond, max: time.Second},  // 达到上限
+		{attempt: 64, min: 500 * time.Millisecond, max: time.Second}, // 移位溢出时按上限处理
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("第%d次重试", tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if d := backoffDelay(p, tt.attempt); d < tt.min || d > tt.max {
+					t.Fatalf("backoffDelay(%d) = %v, want within [%v, %v]", tt.attempt, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePolicies(t *testing.T) {
+	policies := parsePolicies(map[string]Policy{
+		"search": {MaxRetries: -1, Backoff: "bad", MaxBackoff: "2s", Fallback: "backup", FallbackOnEmpty: true},
+	})
+	want := policy{backoff: defaultBackoff, maxBackoff: 2 * time.Second, fallback: "backup", fallbackOnEmpty: true}
+	if got := policies["search"]; got != want {
+		t.Errorf("parsePolicies() = %+v, want %+v", got, want)
+	}
+}
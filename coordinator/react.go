@@ -0,0 +1,186 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcp-server/internal/tool"
+	"strings"
+	"time"
+)
+
+// reactSystemPrompt ReAct模式的系统提示词
+const reactSystemPrompt = "你是一个可以调用外部工具的助手。请根据用户问题按需调用工具，" +
+	"每次工具调用的结果都会返回给你，你可以基于结果继续调用其他工具。" +
+	"当信息足够回答用户问题时，直接给出最终回答，不要再调用工具。"
+
+// runReAct 以原生函数调用的方式驱动模型：工具结果作为tool消息回传给模型，
+// 循环直到模型给出最终回答或达到最大步骤数
+func (c *Coordinator) runReAct(ctx context.Context, userQuery string, history []Turn, handler EventHandler) (*Result, error) {
+	toolInfos := c.buildToolInfos()
+	messages := []*schema.Message{schema.SystemMessage(reactSystemPrompt)}
+	messages = append(messages, historyMessages(history)...)
+	messages = append(messages, schema.UserMessage(userQuery))
+	result := &Result{}
+
+	for step := 0; step < c.cfg.MaxSteps; step++ {
+		resp, err := c.generate(ctx, messages, handler, model.WithTools(toolInfos))
+		if err != nil {
+			return result, fmt.Errorf("步骤%d: 模型调用失败: %v", step+1, err)
+		}
+		if resp == nil {
+			return result, fmt.Errorf("步骤%d: 模型返回内容为空", step+1)
+		}
+		result.Usage.add(resp)
+
+		// 没有工具调用即为最终回答
+		if len(resp.ToolCalls) == 0 {
+			result.Answer = resp.Content
+			return result, nil
+		}
+
+		messages = append(messages, resp)
+		for _, call := range resp.ToolCalls {
+			handler.emit(Event{Type: EventStepStarted, Step: &StepResult{
+				Step:     len(result.Steps) + 1,
+				ToolName: call.Function.Name,
+				Reason:   resp.Content,
+			}})
+			stepResult := c.invokeToolCall(ctx, call)
+			stepResult.Step = len(result.Steps) + 1
+			stepResult.Reason = resp.Content
+			result.Steps = append(result.Steps, stepResult)
+			handler.emit(Event{Type: EventStepFinished, Step: &stepResult})
+
+			// 失败信息同样回传给模型，由模型决定后续动作
+			output := stepResult.Output
+			if stepResult.Status == StepFailed {
+				output = "错误: " + stepResult.Error
+			}
+			messages = append(messages, schema.ToolMessage(output, call.ID))
+		}
+	}
+
+	return result, fmt.Errorf("超过最大步骤数(%d)仍未得到最终回答", c.cfg.MaxSteps)
+}
+
+// invokeToolCall 执行模型发起的单个工具调用，返回该调用的执行记录
+// 工具错误不会中断循环，而是记录为失败状态交给模型自行决定后续动作
+func (c *Coordinator) invokeToolCall(ctx context.Context, call schema.ToolCall) (stepResult StepResult) {
+	stepResult = StepResult{
+		ToolName: call.Function.Name,
+		Status:   StepFailed,
+	}
+	start := time.Now()
+	defer func() {
+		stepResult.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	args := make(map[string]interface{})
+	if strings.TrimSpace(call.Function.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+			stepResult.Error = fmt.Sprintf("工具参数解析失败: %v", err)
+			return stepResult
+		}
+	}
+	stepResult.Params = args
+
+	outcome, err := c.callWithPolicy(ctx, call.Function.Name, args)
+	stepResult.Attempts = outcome.Attempts
+	stepResult.Fallback = outcome.Fallback
+	if err != nil {
+		stepResult.Error = fmt.Sprintf("工具执行失败: %v", err)
+		return stepResult
+	}
+	stepResult.Status = StepSucceeded
+	stepResult.Output = outcome.Text
+	if stepResult.Output == "" {
+		stepResult.Output = "工具执行成功，但未返回结果"
+	}
+	return stepResult
+}
+
+// lookupTool 按MCP描述中的工具名（模型看到的名称）查找工具，兼容注册名
+func (c *Coordinator) lookupTool(name string) (tool.Tool, bool) {
+	return c.toolManager.Lookup(name)
+}
+
+// buildToolInfos 将已注册工具的MCP描述转换为模型可绑定的函数定义
+func (c *Coordinator) buildToolInfos() []*schema.ToolInfo {
+	c.toolManager.Mu().RLock()
+	defer c.toolManager.Mu().RUnlock()
+
+	infos := make([]*schema.ToolInfo, 0, len(c.toolManager.Tools()))
+	for _, t := range c.toolManager.Tools() {
+		descriptor := t.GetDescriptor()
+		required := make(map[string]bool, len(descriptor.InputSchema.Required))
+		for _, name := range descriptor.InputSchema.Required {
+			required[name] = true
+		}
+
+		params := make(map[string]*schema.ParameterInfo, len(descriptor.InputSchema.Properties))
+		for paramName, prop := range descriptor.InputSchema.Properties {
+			propMap, ok := prop.(map[string]any)
+			if !ok {
+				continue
+			}
+			info := toParameterInfo(propMap)
+			info.Required = required[paramName]
+			params[paramName] = info
+		}
+
+		infos = append(infos, &schema.ToolInfo{
+			Name:        descriptor.Name,
+			Desc:        descriptor.Description,
+			ParamsOneOf: schema.NewParamsOneOfByParams(params),
+		})
+	}
+	return infos
+}
+
+// toParameterInfo 将JSON Schema属性转换为eino参数描述
+func toParameterInfo(prop map[string]any) *schema.ParameterInfo {
+	info := &schema.ParameterInfo{
+		Type: schema.String,
+	}
+	if typ, ok := prop["type"].(string); ok && typ != "" {
+		info.Type = schema.DataType(typ)
+	}
+	if desc, ok := prop["description"].(string); ok {
+		info.Desc = desc
+	}
+	switch enum := prop["enum"].(type) {
+	case []string:
+		info.Enum = enum
+	case []any:
+		for _, v := range enum {
+			info.Enum = append(info.Enum, fmt.Sprint(v))
+		}
+	}
+	if items, ok := prop["items"].(map[string]any); ok {
+		info.ElemInfo = toParameterInfo(items)
+	}
+	if props, ok := prop["properties"].(map[string]any); ok {
+		info.SubParams = make(map[string]*schema.ParameterInfo, len(props))
+		for name, sub := range props {
+			if subMap, ok := sub.(map[string]any); ok {
+				info.SubParams[name] = toParameterInfo(subMap)
+			}
+		}
+	}
+	return info
+}
+
+// resultText 拼接工具结果中的所有文本内容
+func resultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
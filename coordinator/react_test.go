@@ -0,0 +1,126 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"mcp-server/internal/tool/tooltest"
+	"strings"
+	"testing"
+)
+
+func TestRunReActToolCallRoundTrip(t *testing.T) {
+	failing := tooltest.New("failing", func(ctx context.Context, args map[string]interface{}) (string, error) {
+		return "", errors.New("backend unavailable")
+	})
+	c := newTestCoordinator(t, nil, tooltest.Echo("echo"), failing)
+	chatModel, fake := newScriptedChatModel(t,
+		fakeReply{Content: "先查询天气", ToolCalls: []fakeToolCall{
+			{Name: "echo", Arguments: `{"query":"北京晴"}`},
+			{Name: "failing", Arguments: `{}`},
+		}},
+		fakeReply{ToolCalls: []fakeToolCall{{Name: "echo", Arguments: `{"query":`}}},
+		fakeReply{Content: "北京今天晴"},
+	)
+	c.model = chatModel
+
+	result, err := c.Execute(context.Background(), "", "北京天气怎么样")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Answer != "北京今天晴" {
+		t.Errorf("Answer = %q", result.Answer)
+	}
+	if result.Usage.TotalTokens != 6 {
+		t.Errorf("TotalTokens = %d, want 6", result.Usage.TotalTokens)
+	}
+
+	wantSteps := []struct {
+		tool, status, output, err, reason string
+	}{
+		{tool: "echo", status: StepSucceeded, output: "北京晴", reason: "先查询天气"},
+		{tool: "failing", status: StepFailed, err: "backend unavailable", reason: "先查询天气"},
+		{tool: "echo", status: StepFailed, err: "工具参数解析失败"},
+	}
+	if len(result.Steps) != len(wantSteps) {
+		t.Fatalf("got %d steps, want %d", len(result.Steps), len(wantSteps))
+	}
+	for i, want := range wantSteps {
+		step := result.Steps[i]
+		if step.Step != i+1 || step.ToolName != want.tool || step.Status != want.status || step.Output != want.output ||
+			step.Reason != want.reason || !strings.Contains(step.Error, want.err) {
+			t.Errorf("steps[%d] = %+v", i, step)
+		}
+	}
+
+	// 每轮都把工具绑定给模型，工具结果（含错误）以tool消息回传并对应调用ID
+	calls := fake.calls()
+	if len(calls) != 3 {
+		t.Fatalf("model called %d times, want 3", len(calls))
+	}
+	if len(calls[0].Tools) != 2 {
+		t.Errorf("first request bound %d tools, want 2", len(calls[0].Tools))
+	}
+	var toolMessages []string
+	for _, m := range calls[1].Messages {
+		if m.Role == "tool" {
+			toolMessages = append(toolMessages, m.ToolCallID+"="+m.Content)
+		}
+	}
+	if len(toolMessages) != 2 || toolMessages[0] != "call_1_1=北京晴" || !strings.HasPrefix(toolMessages[1], "call_1_2=错误: 工具执行失败: backend unavailable") {
+		t.Errorf("tool messages in second request = %q", toolMessages)
+	}
+}
+
+func TestRunReActStopsAtMaxSteps(t *testing.T) {
+	c := newTestCoordinator(t, &Config{MaxSteps: 2}, tooltest.Echo("echo"))
+	loop := fakeReply{ToolCalls: []fakeToolCall{{Name: "echo", Arguments: `{"query":"again"}`}}}
+	chatModel, fake := newScriptedChatModel(t, loop, loop, loop)
+	c.model = chatModel
+
+	result, err := c.Execute(context.Background(), "", "问题")
+	if err == nil || !strings.Contains(err.Error(), "超过最大步骤数(2)") {
+		t.Fatalf("Execute() error = %v, want max steps exceeded", err)
+	}
+	if len(result.Steps) != 2 {
+		t.Errorf("got %d steps, want the 2 executed before the cutoff", len(result.Steps))
+	}
+	if n := len(fake.calls()); n != 2 {
+		t.Errorf("model called %d times, want 2", n)
+	}
+}
+
+func TestRunReActStreamsEvents(t *testing.T) {
+	c := newTestCoordinator(t, nil, tooltest.Echo("echo"))
+	chatModel, fake := newScriptedChatModel(t,
+		fakeReply{ToolCalls: []fakeToolCall{{Name: "echo", Arguments: `{"query":"晴"}`}}},
+		fakeReply{Content: "今天晴"},
+	)
+	c.model = chatModel
+
+	var events []string
+	result, err := c.ExecuteStream(context.Background(), "", "天气", func(e Event) {
+		switch e.Type {
+		case EventToken:
+			events = append(events, e.Delta)
+		case EventFinalAnswer:
+			events = append(events, e.Type+":"+e.Answer)
+		default:
+			events = append(events, e.Type)
+		}
+	})
+	if err != nil {
+		t.Fatalf("ExecuteStream() error = %v", err)
+	}
+	want := "step_started step_finished 今 天 晴 final_answer:今天晴"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	if result.Answer != "今天晴" || len(result.Steps) != 1 || result.Steps[0].Output != "晴" {
+		t.Errorf("result = %+v", result)
+	}
+	for i, call := range fake.calls() {
+		if !call.Stream {
+			t.Errorf("request %d not streamed", i)
+		}
+	}
+}
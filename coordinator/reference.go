@@ -0,0 +1,166 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// referencePattern 匹配参数中的步骤结果引用，如 {{steps[0].result.url}} 或 {{$.steps[1].result[0].name}}
+var referencePattern = regexp.MustCompile(`\{\{\s*(?:\$\.)?steps\[(\d+)\]\.result((?:\.[A-Za-z_][A-Za-z0-9_]*|\[\d+\])*)\s*\}\}`)
+
+// pathSegmentPattern 拆分引用路径中的字段与下标
+var pathSegmentPattern = regexp.MustCompile(`\.([A-Za-z_][A-Za-z0-9_]*)|\[(\d+)\]`)
+
+// stepOutput 已执行步骤的输出（供后续步骤引用）
+type stepOutput struct {
+	Text   string // 工具返回的原始文本
+	Parsed any    // 文本按JSON解析后的值，非JSON时为原始文本
+}
+
+// newStepOutput 根据工具返回文本构造步骤输出
+func newStepOutput(text string) stepOutput {
+	out := stepOutput{Text: text, Parsed: text}
+	var parsed any
+	if err := json.Unmarshal([]byte(text), &parsed); err == nil {
+		out.Parsed = parsed
+	}
+	return out
+}
+
+// resolveReferences 将参数中对已完成步骤结果的引用替换为实际值（outputs按步骤下标存放，未执行的为nil）
+// 参数值恰好是一个完整引用时保留被引用值的原始类型，否则按字符串插值
+func resolveReferences(params map[string]interface{}, outputs []*stepOutput) (map[string]interface{}, error) {
+	resolved, err := resolveValue(params, outputs)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := resolved.(map[string]interface{})
+	return result, nil
+}
+
+// resolveValue 递归解析任意参数值中的引用
+func resolveValue(value any, outputs []*stepOutput) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return resolveString(v, outputs)
+	case map[string]interface{}:
+		if v == nil {
+			return v, nil
+		}
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := resolveValue(item, outputs)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := resolveValue(item, outputs)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
+
+// resolveString 解析字符串中的引用
+func resolveString(s string, outputs []*stepOutput) (any, error) {
+	matches := referencePattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	// 整个字符串就是一个引用：直接返回被引用的值
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return lookupReference(s[matches[0][2]:matches[0][3]], s[matches[0][4]:matches[0][5]], outputs)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		value, err := lookupReference(s[m[2]:m[3]], s[m[4]:m[5]], outputs)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(stringify(value))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// lookupReference 按步骤下标和路径取出被引用的值
+// 对数组访问字段时默认取第一个元素，便于直接引用检索结果中的首条记录
+func lookupReference(indexStr, path string, outputs []*stepOutput) (any, error) {
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		return nil, fmt.Errorf("无效的步骤下标: %s", indexStr)
+	}
+	if index < 0 || index >= len(outputs) || outputs[index] == nil {
+		return nil, fmt.Errorf("引用的步骤 steps[%d] 尚未执行", index)
+	}
+
+	current := outputs[index].Parsed
+	traversed := fmt.Sprintf("steps[%d].result", index)
+	for _, seg := range pathSegmentPattern.FindAllStringSubmatch(path, -1) {
+		if field := seg[1]; field != "" {
+			if arr, ok := current.([]interface{}); ok {
+				if len(arr) == 0 {
+					return nil, fmt.Errorf("%s 为空数组，无法取字段 %s", traversed, field)
+				}
+				current = arr[0]
+			}
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s 不是对象，无法取字段 %s", traversed, field)
+			}
+			value, ok := obj[field]
+			if !ok {
+				return nil, fmt.Errorf("%s 中不存在字段 %s", traversed, field)
+			}
+			current = value
+			traversed += "." + field
+			continue
+		}
+
+		i, _ := strconv.Atoi(seg[2])
+		arr, ok := current.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s 不是数组，无法取下标 %d", traversed, i)
+		}
+		if i >= len(arr) {
+			return nil, fmt.Errorf("%s 下标 %d 越界（长度%d）", traversed, i, len(arr))
+		}
+		current = arr[i]
+		traversed += fmt.Sprintf("[%d]", i)
+	}
+	return current, nil
+}
+
+// stringify 将引用值转换为插值用的字符串
+func stringify(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,110 @@
+package coordinator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	first := newStepOutput(`[{"name":"门票","url":"https://a.example/ticket"},{"name":"酒店","url":"https://a.example/hotel"}]`)
+	second := newStepOutput(`纯文本结果`)
+	third := newStepOutput(`{"count":3,"tags":["a","b"]}`)
+	outputs := []*stepOutput{&first, &second, &third, nil}
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "无引用原样返回",
+			params: map[string]interface{}{"query": "北京", "n": 2.0},
+			want:   map[string]interface{}{"query": "北京", "n": 2.0},
+		},
+		{
+			name:   "数组取字段默认取第一个元素",
+			params: map[string]interface{}{"url": "{{steps[0].result.url}}"},
+			want:   map[string]interface{}{"url": "https://a.example/ticket"},
+		},
+		{
+			name:   "下标与字段",
+			params: map[string]interface{}{"name": "{{ $.steps[0].result[1].name }}"},
+			want:   map[string]interface{}{"name": "酒店"},
+		},
+		{
+			name:   "完整引用保留原始类型",
+			params: map[string]interface{}{"count": "{{steps[2].result.count}}", "tags": "{{steps[2].result.tags}}"},
+			want:   map[string]interface{}{"count": 3.0, "tags": []interface{}{"a", "b"}},
+		},
+		{
+			name:   "字符串插值",
+			params: map[string]interface{}{"q": "打开 {{steps[0].result[1].url}} 并总结：{{steps[1].result}} {{steps[2].result.tags}}"},
+			want:   map[string]interface{}{"q": `打开 https://a.example/hotel 并总结：纯文本结果 ["a","b"]`},
+		},
+		{
+			name:   "嵌套对象和数组",
+			params: map[string]interface{}{"opts": map[string]interface{}{"urls": []interface{}{"{{steps[0].result[0].url}}", "x"}}},
+			want:   map[string]interface{}{"opts": map[string]interface{}{"urls": []interface{}{"https://a.example/ticket", "x"}}},
+		},
+		{
+			name:    "引用未执行的步骤",
+			params:  map[string]interface{}{"q": "{{steps[3].result}}"},
+			wantErr: "尚未执行",
+		},
+		{
+			name:    "引用越界的步骤",
+			params:  map[string]interface{}{"q": "{{steps[9].result}}"},
+			wantErr: "尚未执行",
+		},
+		{
+			name:    "字段不存在",
+			params:  map[string]interface{}{"q": "{{steps[2].result.missing}}"},
+			wantErr: "不存在字段 missing",
+		},
+		{
+			name:    "文本结果取字段",
+			params:  map[string]interface{}{"q": "{{steps[1].result.url}}"},
+			wantErr: "不是对象",
+		},
+		{
+			name:    "数组下标越界",
+			params:  map[string]interface{}{"q": "{{steps[0].result[5]}}"},
+			wantErr: "越界",
+		},
+		{
+			name:    "错误信息包含参数路径",
+			params:  map[string]interface{}{"opts": []interface{}{"{{steps[2].result[0]}}"}},
+			wantErr: "opts: [0]: steps[2].result 不是数组",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveReferences(tt.params, outputs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveReferences() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveReferences() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveReferences() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveReferencesDoesNotMutateParams(t *testing.T) {
+	out := newStepOutput(`{"url":"u"}`)
+	params := map[string]interface{}{"url": "{{steps[0].result.url}}"}
+	if _, err := resolveReferences(params, []*stepOutput{&out}); err != nil {
+		t.Fatal(err)
+	}
+	if params["url"] != "{{steps[0].result.url}}" {
+		t.Errorf("params mutated: %v", params)
+	}
+}
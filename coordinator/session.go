@@ -0,0 +1,290 @@
+package coordinator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/cloudwego/eino/schema"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 会话存储类型
+const (
+	SessionStoreMemory = "memory" // 进程内存（默认）
+	SessionStoreFile   = "file"   // 本地目录，每个会话一个JSON文件
+)
+
+const (
+	defaultMaxHistory = 10        // 默认每个会话保留的历史轮数
+	defaultSessionTTL = time.Hour // 默认会话空闲过期时间
+	// maxHistoryAnswerRunes 历史回答提供给模型时保留的最大字符数
+	maxHistoryAnswerRunes = 2000
+)
+
+// SessionConfig 会话存储配置
+type SessionConfig struct {
+	Store      string `json:"store" jsonschema:"enum=memory|file"` // 存储类型（memory / file），默认memory
+	Dir        string `json:"dir"`                                 // file存储的目录
+	MaxHistory int    `json:"max_history" jsonschema:"minimum=0"`  // 每个会话保留的最近轮数
+	TTL        string `json:"ttl" jsonschema:"format=duration"`    // 会话空闲过期时间（如"30m"）
+}
+
+// Turn 会话中的一轮问答
+type Turn struct {
+	Query  string        `json:"query"`          // 用户查询
+	Plan   *ToolCallPlan `json:"plan,omitempty"` // 该轮的调用计划（仅计划模式）
+	Answer string        `json:"answer"`         // 最终回答
+	Time   time.Time     `json:"time"`           // 完成时间
+}
+
+// SessionStore 会话历史存储（可替换为其他实现，如嵌入式KV）
+type SessionStore interface {
+	// History 返回会话的历史轮次（按时间顺序），会话不存在或已过期时返回空
+	History(ctx context.Context, sessionID string) ([]Turn, error)
+	// Append 追加一轮问答，超出最大轮数时丢弃最早的记录
+	Append(ctx context.Context, sessionID string, turn Turn) error
+}
+
+// NewSessionStore 根据配置创建会话存储
+func NewSessionStore(cfg SessionConfig) (SessionStore, error) {
+	maxHistory := cfg.MaxHistory
+	if maxHistory <= 0 {
+		maxHistory = defaultMaxHistory
+	}
+	ttl := defaultSessionTTL
+	if cfg.TTL != "" {
+		d, err := time.ParseDuration(cfg.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session ttl %q: %w", cfg.TTL, err)
+		}
+		ttl = d
+	}
+
+	switch cfg.Store {
+	case "", SessionStoreMemory:
+		return NewMemorySessionStore(maxHistory, ttl), nil
+	case SessionStoreFile:
+		return NewFileSessionStore(cfg.Dir, maxHistory, ttl)
+	default:
+		return nil, fmt.Errorf("unknown session store: %s", cfg.Store)
+	}
+}
+
+// session 单个会话的历史记录
+type session struct {
+	Turns     []Turn    `json:"turns"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// expired 会话是否已超过空闲过期时间
+func (s *session) expired(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(s.UpdatedAt) > ttl
+}
+
+// append 追加一轮并裁剪到最大轮数
+func (s *session) append(turn Turn, maxHistory int, now time.Time) {
+	s.Turns = append(s.Turns, turn)
+	if len(s.Turns) > maxHistory {
+		s.Turns = s.Turns[len(s.Turns)-maxHistory:]
+	}
+	s.UpdatedAt = now
+}
+
+// MemorySessionStore 进程内存会话存储
+// 访问到的过期会话立即丢弃；其他过期会话在写入时顺带清理，每个TTL周期最多扫描一次
+type MemorySessionStore struct {
+	sessions   map[string]*session
+	maxHistory int
+	ttl        time.Duration
+	lastSweep  time.Time // 上次扫描清理过期会话的时间
+	mu         sync.Mutex
+}
+
+// NewMemorySessionStore 创建内存会话存储
+func NewMemorySessionStore(maxHistory int, ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions:   make(map[string]*session),
+		maxHistory: maxHistory,
+		ttl:        ttl,
+	}
+}
+
+// History 实现SessionStore接口
+func (s *MemorySessionStore) History(ctx context.Context, sessionID string) ([]Turn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	if sess.expired(s.ttl, time.Now()) {
+		delete(s.sessions, sessionID)
+		return nil, nil
+	}
+	return append([]Turn(nil), sess.Turns...), nil
+}
+
+// Append 实现SessionStore接口
+func (s *MemorySessionStore) Append(ctx context.Context, sessionID string, turn Turn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if sweepDue(s.ttl, s.lastSweep, now) {
+		for id, sess := range s.sessions {
+			if sess.expired(s.ttl, now) {
+				delete(s.sessions, id)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	sess, ok := s.sessions[sessionID]
+	if !ok || sess.expired(s.ttl, now) {
+		sess = &session{}
+		s.sessions[sessionID] = sess
+	}
+	sess.append(turn, s.maxHistory, now)
+	return nil
+}
+
+// sweepDue 是否需要扫描清理过期会话：设置了TTL且距上次扫描已超过一个TTL周期
+func sweepDue(ttl time.Duration, lastSweep, now time.Time) bool {
+	return ttl > 0 && now.Sub(lastSweep) > ttl
+}
+
+// FileSessionStore 文件会话存储，每个会话保存为目录下的一个JSON文件，重启后历史仍然可用
+// 过期会话的清理方式与MemorySessionStore相同
+type FileSessionStore struct {
+	dir        string
+	maxHistory int
+	ttl        time.Duration
+	lastSweep  time.Time // 上次扫描清理过期会话文件的时间
+	mu         sync.Mutex
+}
+
+// NewFileSessionStore 创建文件会话存储（目录不存在时自动创建）
+func NewFileSessionStore(dir string, maxHistory int, ttl time.Duration) (*FileSessionStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("session dir is required for file store")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create session dir failed: %w", err)
+	}
+	return &FileSessionStore{
+		dir:        dir,
+		maxHistory: maxHistory,
+		ttl:        ttl,
+	}, nil
+}
+
+// path 会话文件路径（对会话ID做哈希，避免非法文件名）
+func (s *FileSessionStore) path(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// load 读取会话文件，不存在时返回nil
+func (s *FileSessionStore) load(path string) (*session, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session file failed: %w", err)
+	}
+	sess := &session{}
+	if err := json.Unmarshal(data, sess); err != nil {
+		return nil, fmt.Errorf("parse session file failed: %w", err)
+	}
+	return sess, nil
+}
+
+// History 实现SessionStore接口
+func (s *FileSessionStore) History(ctx context.Context, sessionID string) ([]Turn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(sessionID)
+	sess, err := s.load(path)
+	if err != nil || sess == nil {
+		return nil, err
+	}
+	if sess.expired(s.ttl, time.Now()) {
+		os.Remove(path)
+		return nil, nil
+	}
+	return sess.Turns, nil
+}
+
+// Append 实现SessionStore接口
+func (s *FileSessionStore) Append(ctx context.Context, sessionID string, turn Turn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if sweepDue(s.ttl, s.lastSweep, now) {
+		s.evictExpired(now)
+		s.lastSweep = now
+	}
+
+	path := s.path(sessionID)
+	sess, err := s.load(path)
+	if err != nil {
+		return err
+	}
+	if sess == nil || sess.expired(s.ttl, now) {
+		sess = &session{}
+	}
+	sess.append(turn, s.maxHistory, now)
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("marshal session failed: %w", err)
+	}
+	// 先写临时文件再重命名，避免写入中途崩溃留下损坏的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write session file failed: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// evictExpired 按文件修改时间清理过期会话
+func (s *FileSessionStore) evictExpired(now time.Time) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err == nil && now.Sub(info.ModTime()) > s.ttl {
+			os.Remove(filepath.Join(s.dir, entry.Name()))
+		}
+	}
+}
+
+// historyMessages 将会话历史转换为模型的上下文消息
+func historyMessages(turns []Turn) []*schema.Message {
+	messages := make([]*schema.Message, 0, len(turns)*2)
+	for _, turn := range turns {
+		var b strings.Builder
+		if turn.Plan != nil {
+			plan, _ := json.Marshal(turn.Plan)
+			fmt.Fprintf(&b, "调用计划：%s\n", plan)
+		}
+		b.WriteString(truncateRunes(turn.Answer, maxHistoryAnswerRunes))
+		messages = append(messages, schema.UserMessage(turn.Query), schema.AssistantMessage(b.String(), nil))
+	}
+	return messages
+}
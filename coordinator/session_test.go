@@ -0,0 +1,222 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// appendQueries 依次向会话追加以queries为查询的轮次
+func appendQueries(t *testing.T, store SessionStore, sessionID string, queries ...string) {
+	t.Helper()
+	for _, q := range queries {
+		if err := store.Append(context.Background(), sessionID, Turn{Query: q, Answer: "答:" + q}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// historyQueries 返回会话历史中各轮的查询
+func historyQueries(t *testing.T, store SessionStore, sessionID string) []string {
+	t.Helper()
+	turns, err := store.History(context.Background(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := make([]string, 0, len(turns))
+	for _, turn := range turns {
+		queries = append(queries, turn.Query)
+	}
+	return queries
+}
+
+// newSessionStores 按存储类型创建测试用会话存储
+func newSessionStores(t *testing.T, maxHistory int, ttl time.Duration) map[string]SessionStore {
+	t.Helper()
+	fileStore, err := NewFileSessionStore(t.TempDir(), maxHistory, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]SessionStore{
+		SessionStoreMemory: NewMemorySessionStore(maxHistory, ttl),
+		SessionStoreFile:   fileStore,
+	}
+}
+
+func TestSessionStoreHistoryLimit(t *testing.T) {
+	for name, store := range newSessionStores(t, 3, time.Hour) {
+		t.Run(name, func(t *testing.T) {
+			appendQueries(t, store, "s1", "q1", "q2", "q3", "q4", "q5")
+			appendQueries(t, store, "s2", "other")
+
+			if got := fmt.Sprint(historyQueries(t, store, "s1")); got != "[q3 q4 q5]" {
+				t.Errorf("s1 history = %s, want the last 3 turns in order", got)
+			}
+			if got := fmt.Sprint(historyQueries(t, store, "s2")); got != "[other]" {
+				t.Errorf("s2 history = %s", got)
+			}
+			if got := historyQueries(t, store, "unknown"); len(got) != 0 {
+				t.Errorf("unknown session history = %v, want empty", got)
+			}
+		})
+	}
+}
+
+func TestMemorySessionStoreTTL(t *testing.T) {
+	store := NewMemorySessionStore(10, time.Minute)
+	appendQueries(t, store, "stale", "old")
+	appendQueries(t, store, "idle", "old")
+	appendQueries(t, store, "active", "q1")
+
+	// 模拟stale与idle超过一分钟未活跃
+	expire := func(ids ...string) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		for _, id := range ids {
+			store.sessions[id].UpdatedAt = time.Now().Add(-2 * time.Minute)
+		}
+	}
+	expire("stale", "idle")
+
+	if got := historyQueries(t, store, "stale"); len(got) != 0 {
+		t.Errorf("expired session history = %v, want empty", got)
+	}
+	// 过期会话再次写入时从空历史开始
+	appendQueries(t, store, "idle", "new")
+	if got := fmt.Sprint(historyQueries(t, store, "idle")); got != "[new]" {
+		t.Errorf("history after expiry = %s, want [new]", got)
+	}
+
+	// 距上次扫描不足一个TTL周期时写入不扫描其他会话
+	expire("active")
+	appendQueries(t, store, "idle", "again")
+	store.mu.Lock()
+	_, kept := store.sessions["active"]
+	store.mu.Unlock()
+	if !kept {
+		t.Error("expired session evicted before the sweep interval elapsed")
+	}
+
+	// 超过扫描周期后写入任意会话时清理所有过期会话
+	store.mu.Lock()
+	store.lastSweep = time.Now().Add(-2 * time.Minute)
+	store.mu.Unlock()
+	appendQueries(t, store, "idle", "later")
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.sessions["active"]; ok || len(store.sessions) != 1 {
+		t.Errorf("sessions after sweep = %d, want only the written one", len(store.sessions))
+	}
+}
+
+func TestFileSessionStoreTTL(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSessionStore(dir, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendQueries(t, store, "stale", "old")
+	appendQueries(t, store, "idle", "old")
+
+	// 修改时间与会话内的更新时间都早于TTL
+	past := time.Now().Add(-2 * time.Minute)
+	for _, id := range []string{"stale", "idle"} {
+		path := store.path(id)
+		sess, err := store.load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess.UpdatedAt = past
+		writeSession(t, path, sess)
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := historyQueries(t, store, "stale"); len(got) != 0 {
+		t.Errorf("expired session history = %v, want empty", got)
+	}
+	if _, err := os.Stat(store.path("stale")); !os.IsNotExist(err) {
+		t.Errorf("expired session file not removed: %v", err)
+	}
+
+	// 重启后的首次写入清理遗留的过期会话文件
+	restarted, err := NewFileSessionStore(dir, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendQueries(t, restarted, "fresh", "q1")
+	if _, err := os.Stat(store.path("idle")); !os.IsNotExist(err) {
+		t.Errorf("expired session file not swept: %v", err)
+	}
+}
+
+// writeSession 直接写入会话文件
+func writeSession(t *testing.T, path string, sess *session) {
+	t.Helper()
+	data, err := json.Marshal(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSessionStorePersistsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSessionStore(dir, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendQueries(t, store, "s1", "q1", "q2")
+
+	restarted, err := NewFileSessionStore(dir, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(historyQueries(t, restarted, "s1")); got != "[q1 q2]" {
+		t.Errorf("history after restart = %s, want [q1 q2]", got)
+	}
+	appendQueries(t, restarted, "s1", "q3", "q4")
+	if got := fmt.Sprint(historyQueries(t, restarted, "s1")); got != "[q2 q3 q4]" {
+		t.Errorf("history = %s, want [q2 q3 q4]", got)
+	}
+}
+
+func TestSessionStoreConcurrentAppend(t *testing.T) {
+	const writers = 20
+	for name, store := range newSessionStores(t, writers, time.Hour) {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					ctx := context.Background()
+					if err := store.Append(ctx, "shared", Turn{Query: fmt.Sprintf("q%d", i)}); err != nil {
+						t.Error(err)
+					}
+					if err := store.Append(ctx, fmt.Sprintf("own-%d", i), Turn{Query: "q"}); err != nil {
+						t.Error(err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			// 同一会话的并发写入不丢失
+			if got := historyQueries(t, store, "shared"); len(got) != writers {
+				t.Errorf("shared history has %d turns, want %d", len(got), writers)
+			}
+			for i := 0; i < writers; i++ {
+				if got := historyQueries(t, store, fmt.Sprintf("own-%d", i)); len(got) != 1 {
+					t.Errorf("own-%d history = %v", i, got)
+				}
+			}
+		})
+	}
+}
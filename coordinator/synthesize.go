@@ -0,0 +1,52 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"github.com/cloudwego/eino/schema"
+	"strings"
+)
+
+// maxSynthesisOutputRunes 汇总时每个步骤结果最多提供给模型的字符数
+const maxSynthesisOutputRunes = 4000
+
+// synthesisSystemPrompt 汇总阶段的系统提示词
+const synthesisSystemPrompt = "你是一个回答整理助手。请仅根据给出的工具调用结果，用自然语言回答用户问题。" +
+	"每个事实后用[步骤N]标注其来源步骤；如果结果不足以回答问题，请如实说明，不要编造。"
+
+// synthesize 将用户问题与各步骤的调用原因、结果交给模型，生成带来源标注的最终回答
+func (c *Coordinator) synthesize(ctx context.Context, userQuery string, steps []StepResult, usage *TokenUsage, handler EventHandler) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "用户问题：%s\n\n工具调用结果：\n", userQuery)
+	for _, step := range steps {
+		output := truncateRunes(step.Output, maxSynthesisOutputRunes)
+		if step.Status != StepSucceeded {
+			output = fmt.Sprintf("（未成功: %s）", step.Error)
+		}
+		fmt.Fprintf(&b, "[步骤%d] 工具: %s\n调用原因: %s\n结果: %s\n\n",
+			step.Step, step.ToolName, step.Reason, output)
+	}
+
+	messages := []*schema.Message{
+		schema.SystemMessage(synthesisSystemPrompt),
+		schema.UserMessage(b.String()),
+	}
+	resp, err := c.generate(ctx, messages, handler)
+	if err != nil {
+		return "", fmt.Errorf("模型调用失败: %v", err)
+	}
+	usage.add(resp)
+	if resp == nil || resp.Content == "" {
+		return "", fmt.Errorf("模型返回内容为空")
+	}
+	return resp.Content, nil
+}
+
+// truncateRunes 按字符数截断文本，超出部分以省略号表示
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "..."
+}
@@ -0,0 +1,55 @@
+package coordinator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSynthesize(t *testing.T) {
+	c := newTestCoordinator(t, nil)
+	chatModel, fake := newScriptedChatModel(t, fakeReply{Content: "北京今天晴[步骤1]，上海的天气未能查到[步骤2]。"})
+	c.model = chatModel
+
+	steps := []StepResult{
+		{Step: 1, ToolName: "weather", Reason: "查询北京天气", Status: StepSucceeded, Output: strings.Repeat("晴", maxSynthesisOutputRunes+10)},
+		{Step: 2, ToolName: "weather", Reason: "查询上海天气", Status: StepFailed, Error: "超时"},
+	}
+	var usage TokenUsage
+	answer, err := c.synthesize(context.Background(), "北京和上海的天气", steps, &usage, nil)
+	if err != nil {
+		t.Fatalf("synthesize() error = %v", err)
+	}
+	if answer != "北京今天晴[步骤1]，上海的天气未能查到[步骤2]。" || usage.TotalTokens != 2 {
+		t.Errorf("synthesize() = %q, usage %+v", answer, usage)
+	}
+
+	calls := fake.calls()
+	if len(calls) != 1 || len(calls[0].Messages) != 2 {
+		t.Fatalf("requests = %+v", calls)
+	}
+	if system := calls[0].Messages[0].Content; !strings.Contains(system, "[步骤N]") {
+		t.Errorf("system prompt does not ask for [步骤N] citations: %q", system)
+	}
+	prompt := calls[0].Messages[1].Content
+	wantParts := []string{
+		"用户问题：北京和上海的天气",
+		"[步骤1] 工具: weather\n调用原因: 查询北京天气\n结果: " + strings.Repeat("晴", maxSynthesisOutputRunes) + "...\n\n",
+		"[步骤2] 工具: weather\n调用原因: 查询上海天气\n结果: （未成功: 超时）\n\n",
+	}
+	for _, part := range wantParts {
+		if !strings.Contains(prompt, part) {
+			t.Errorf("prompt missing %q", truncateRunes(part, 80))
+		}
+	}
+}
+
+func TestSynthesizeEmptyAnswer(t *testing.T) {
+	c := newTestCoordinator(t, nil)
+	c.model, _ = newScriptedChatModel(t, fakeReply{})
+
+	var usage TokenUsage
+	if _, err := c.synthesize(context.Background(), "问题", nil, &usage, nil); err == nil || !strings.Contains(err.Error(), "模型返回内容为空") {
+		t.Errorf("synthesize() error = %v, want empty answer error", err)
+	}
+}
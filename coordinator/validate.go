@@ -0,0 +1,83 @@
+package coordinator
+
+import (
+	"fmt"
+	"mcp-server/internal/jsonvalue"
+	"sort"
+)
+
+// defaultMaxRepairAttempts 计划解析或校验失败后默认允许模型修正的次数
+const defaultMaxRepairAttempts = 2
+
+// validatePlan 在执行任何步骤之前校验整个计划：工具是否存在、必填参数、参数类型与枚举值，以及步骤依赖
+// 返回所有发现的问题，为空表示校验通过
+func (c *Coordinator) validatePlan(plan *ToolCallPlan) []string {
+	var problems []string
+	for i, step := range plan.Steps {
+		prefix := fmt.Sprintf("steps[%d]", i)
+		t, ok := c.lookupTool(step.ToolName)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: 工具 %q 不存在", prefix, step.ToolName))
+			continue
+		}
+		schema := t.GetDescriptor().InputSchema
+
+		for _, name := range schema.Required {
+			if _, ok := step.Params[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.params.%s: 缺少必填参数", prefix, name))
+			}
+		}
+
+		names := make([]string, 0, len(step.Params))
+		for name := range step.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := schema.Properties[name].(map[string]any)
+			if !ok {
+				continue
+			}
+			if problem := validateValue(step.Params[name], prop); problem != "" {
+				problems = append(problems, fmt.Sprintf("%s.params.%s: %s", prefix, name, problem))
+			}
+		}
+	}
+
+	if _, err := planDependencies(plan); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// validateValue 按JSON Schema属性校验单个参数值，返回问题描述（通过时为空）
+// 含步骤结果引用的字符串在执行时才能确定实际值，跳过校验
+func validateValue(value any, prop map[string]any) string {
+	if s, ok := value.(string); ok && referencePattern.MatchString(s) {
+		return ""
+	}
+
+	if typ, ok := prop["type"].(string); ok && !jsonvalue.MatchesType(value, typ) {
+		return fmt.Sprintf("类型应为 %s，实际为 %s", typ, jsonvalue.TypeName(value))
+	}
+
+	var enum []string
+	switch e := prop["enum"].(type) {
+	case []string:
+		enum = e
+	case []any:
+		for _, v := range e {
+			enum = append(enum, fmt.Sprint(v))
+		}
+	}
+	if len(enum) > 0 {
+		actual := fmt.Sprint(value)
+		for _, allowed := range enum {
+			if actual == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("取值 %q 不在允许的范围 %v 内", actual, enum)
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package coordinator
+
+import (
+	"context"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcp-server/internal/tool/tooltest"
+	"strings"
+	"testing"
+)
+
+// searchTool 参数定义较完整的测试工具：query必填，limit为整数，mode只能取web或news
+func searchTool() *tooltest.Tool {
+	t := tooltest.Echo("search")
+	t.Params = []mcp.ToolOption{
+		mcp.WithString("query", mcp.Required()),
+		mcp.WithNumber("limit"),
+		mcp.WithString("mode", mcp.Enum("web", "news")),
+		mcp.WithBoolean("safe"),
+	}
+	return t
+}
+
+func TestValidateValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		prop  map[string]any
+		want  string
+	}{
+		{name: "类型匹配", value: "go", prop: map[string]any{"type": "string"}},
+		{name: "字符串应为数字", value: "ten", prop: map[string]any{"type": "number"}, want: "类型应为 number，实际为 string"},
+		{name: "数字应为布尔", value: 1.0, prop: map[string]any{"type": "boolean"}, want: "类型应为 boolean"},
+		{name: "小数不是整数", value: 1.5, prop: map[string]any{"type": "integer"}, want: "类型应为 integer"},
+		{name: "未声明类型", value: 1.0, prop: map[string]any{}},
+		{name: "枚举值", value: "web", prop: map[string]any{"type": "string", "enum": []string{"web", "news"}}},
+		{name: "枚举值之外", value: "fast", prop: map[string]any{"type": "string", "enum": []string{"web", "news"}}, want: `取值 "fast" 不在允许的范围 [web news] 内`},
+		{name: "JSON解析的枚举", value: 2.0, prop: map[string]any{"enum": []any{1.0, 2.0}}},
+		{name: "步骤结果引用跳过校验", value: "{{steps[0].result.count}}", prop: map[string]any{"type": "number"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateValue(tt.value, tt.prop)
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("validateValue(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePlan(t *testing.T) {
+	c := newTestCoordinator(t, nil, searchTool())
+
+	tests := []struct {
+		name  string
+		steps []ToolCallStep
+		want  []string
+	}{
+		{
+			name:  "通过",
+			steps: []ToolCallStep{{ToolName: "search", Params: map[string]any{"query": "go", "limit": 3.0, "mode": "news", "safe": true}}},
+		},
+		{
+			name:  "缺少必填参数",
+			steps: []ToolCallStep{{ToolName: "search", Params: map[string]any{"limit": 3.0}}},
+			want:  []string{"steps[0].params.query: 缺少必填参数"},
+		},
+		{
+			name:  "参数类型错误",
+			steps: []ToolCallStep{{ToolName: "search", Params: map[string]any{"query": "go", "limit": "3", "safe": "yes"}}},
+			want: []string{
+				"steps[0].params.limit: 类型应为 number，实际为 string",
+				"steps[0].params.safe: 类型应为 boolean，实际为 string",
+			},
+		},
+		{
+			name:  "枚举值之外",
+			steps: []ToolCallStep{{ToolName: "search", Params: map[string]any{"query": "go", "mode": "images"}}},
+			want:  []string{`steps[0].params.mode: 取值 "images" 不在允许的范围 [web news] 内`},
+		},
+		{
+			name:  "未声明的参数不校验",
+			steps: []ToolCallStep{{ToolName: "search", Params: map[string]any{"query": "go", "extra": 1.0}}},
+		},
+		{
+			name:  "工具不存在",
+			steps: []ToolCallStep{{ToolName: "search", Params: map[string]any{"query": "go"}}, {ToolName: "translate"}},
+			want:  []string{`steps[1]: 工具 "translate" 不存在`},
+		},
+		{
+			name: "步骤循环依赖",
+			steps: []ToolCallStep{
+				{ToolName: "search", Params: map[string]any{"query": "{{steps[1].result}}"}},
+				{ToolName: "search", Params: map[string]any{"query": "{{steps[0].result}}"}},
+			},
+			want: []string{"循环依赖"},
+		},
+		{
+			name:  "多个问题全部报告",
+			steps: []ToolCallStep{{ToolName: "search", Params: map[string]any{"mode": "images"}}, {ToolName: "translate"}},
+			want: []string{
+				"steps[0].params.query: 缺少必填参数",
+				`steps[0].params.mode: 取值 "images"`,
+				`steps[1]: 工具 "translate" 不存在`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := c.validatePlan(&ToolCallPlan{Steps: tt.steps})
+			if len(problems) != len(tt.want) {
+				t.Fatalf("problems = %q, want %d problems", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problems[%d] = %q, want %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateToolCallPlanRepair(t *testing.T) {
+	const (
+		valid   = `{"steps":[{"tool_name":"search","params":{"query":"go"}}]}`
+		invalid = `{"steps":[{"tool_name":"search","params":{"limit":"3"}}]}`
+		garbage = `我无法生成计划`
+	)
+
+	tests := []struct {
+		name     string
+		attempts int // 0 使用默认修正次数
+		replies  []string
+		wantErr  string
+	}{
+		{name: "首次通过", replies: []string{valid}},
+		{name: "修正校验问题", replies: []string{invalid, valid}},
+		{name: "修正解析错误", replies: []string{garbage, valid}},
+		{name: "校验始终失败时放弃", replies: []string{invalid, invalid, invalid}, wantErr: "计划校验失败（已修正2次）"},
+		{name: "解析始终失败时放弃", replies: []string{garbage, garbage, garbage}, wantErr: "解析工具调用计划失败"},
+		{name: "按配置的修正次数放弃", attempts: 1, replies: []string{invalid, invalid}, wantErr: "计划校验失败（已修正1次）"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCoordinator(t, &Config{Mode: ModePlan, MaxRepairAttempts: tt.attempts}, searchTool())
+			// 模型多于预期被调用时假端点返回错误
+			c.model = newFakeChatModel(t, tt.replies...)
+
+			plan, usage, err := c.Plan(context.Background(), "问题")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Plan() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			} else if len(plan.Steps) != 1 || plan.Steps[0].Params["query"] != "go" {
+				t.Errorf("plan = %+v", plan)
+			}
+			if want := 2 * len(tt.replies); usage.TotalTokens != want {
+				t.Errorf("TotalTokens = %d, want %d (one model call per reply)", usage.TotalTokens, want)
+			}
+		})
+	}
+}
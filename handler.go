@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+	"io"
+	"mcp-server/coordinator"
+	"mcp-server/internal/tool"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTraceOutputRunes 响应中每个步骤输出保留的最大字符数
+const maxTraceOutputRunes = 2000
+
+// messageRequest /messages 请求体
+type messageRequest struct {
+	Query string `json:"query"`
+}
+
+// stepTrace 单个步骤的执行轨迹
+type stepTrace struct {
+	Step       int                    `json:"step"`
+	ToolName   string                 `json:"tool_name"`
+	Params     map[string]interface{} `json:"params"`
+	Reason     string                 `json:"reason"`
+	Status     string                 `json:"status"`
+	DurationMs int64                  `json:"duration_ms"`
+	Output     string                 `json:"output"`
+	Truncated  bool                   `json:"truncated"` // 输出是否被截断
+	Error      string                 `json:"error,omitempty"`
+	Attempts   int                    `json:"attempts"`
+	Fallback   string                 `json:"fallback,omitempty"`
+}
+
+// messageResponse /messages 响应体（包含完整执行轨迹）
+type messageResponse struct {
+	RequestID string                    `json:"request_id"`
+	Answer    string                    `json:"answer"`
+	Result    string                    `json:"result"` // 兼容旧版客户端，与answer相同
+	Plan      *coordinator.ToolCallPlan `json:"plan,omitempty"`
+	Steps     []stepTrace               `json:"steps"`
+	Usage     coordinator.TokenUsage    `json:"usage"`
+	Error     string                    `json:"error,omitempty"`
+}
+
+// progressNotificationMethod 通过MCP会话推送协调器进度事件时使用的通知方法名
+const progressNotificationMethod = "notifications/coordinator/progress"
+
+// newMessagesHandler 创建调用协调器处理客户端查询的HTTP处理器
+// 携带 stream=true 时以SSE格式分块返回进度事件；sessionId对应已连接的MCP会话时，进度事件同时以通知推送到该会话
+// sessionId用作会话历史与按客户端限流的键，只接受sessionKnown认可的会话ID，未携带时按匿名客户端处理
+func newMessagesHandler(coor *coordinator.Coordinator, svr *server.MCPServer, sessionKnown func(string) bool, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		clientID := r.URL.Query().Get("sessionId")
+		if clientID != "" && !sessionKnown(clientID) {
+			logger.Warn("未知的会话ID", zap.String("request_id", requestID), zap.String("client", clientID))
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		// 解析客户端发送的消息
+		var clientRequest messageRequest
+		if err := json.NewDecoder(r.Body).Decode(&clientRequest); err != nil {
+			logger.Error("解析客户端消息失败", zap.String("request_id", requestID), zap.Error(err))
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		logger.Info("收到客户端查询",
+			zap.String("request_id", requestID),
+			zap.String("client", clientID),
+			zap.String("query", clientRequest.Query))
+
+		var stream *eventStream
+		if r.URL.Query().Get("stream") == "true" {
+			flusher, ok := w.(http.Flusher)
+			if !ok {
+				http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Connection", "keep-alive")
+			w.WriteHeader(http.StatusOK)
+			stream = &eventStream{ctx: r.Context(), w: w, flusher: flusher}
+		}
+		handler := progressHandler(requestID, clientID, stream, svr, logger)
+
+		// 调用协调器处理查询（失败时仍返回已产生的执行轨迹）
+		result, err := coor.ExecuteStream(r.Context(), clientID, clientRequest.Query, handler)
+		resp := buildMessageResponse(requestID, result)
+		status := http.StatusOK
+		if err != nil {
+			logger.Error("处理失败", zap.String("request_id", requestID), zap.Error(err))
+			resp.Error = err.Error()
+			status = http.StatusInternalServerError
+		}
+
+		if stream != nil {
+			stream.send("result", resp)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// progressHandler 构造进度事件回调：写入分块响应，并推送到sessionId对应的MCP会话
+// 两者都不可用时返回nil，协调器将以非流式方式执行
+func progressHandler(requestID, sessionID string, stream *eventStream, svr *server.MCPServer, logger *zap.Logger) coordinator.EventHandler {
+	notify := sessionID != ""
+	if stream == nil && !notify {
+		return nil
+	}
+
+	return func(event coordinator.Event) {
+		if stream != nil {
+			stream.send(event.Type, event)
+		}
+		if !notify {
+			return
+		}
+
+		data, _ := json.Marshal(event)
+		params := map[string]any{}
+		json.Unmarshal(data, &params)
+		params["request_id"] = requestID
+		if err := svr.SendNotificationToSpecificClient(sessionID, progressNotificationMethod, params); err != nil {
+			// 会话不存在或未初始化时不再继续推送
+			logger.Warn("推送进度事件失败", zap.String("request_id", requestID), zap.String("client", sessionID), zap.Error(err))
+			notify = false
+		}
+	}
+}
+
+// eventStream 以SSE格式分块写出事件
+type eventStream struct {
+	ctx     context.Context // 请求上下文，客户端断开后不再写出
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// send 写出一个事件并立即刷新，客户端已断开时忽略
+func (s *eventStream) send(event string, data any) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	s.flusher.Flush()
+}
+
+// buildMessageResponse 将协调器结果转换为响应体，步骤输出按长度截断
+func buildMessageResponse(requestID string, result *coordinator.Result) *messageResponse {
+	resp := &messageResponse{
+		RequestID: requestID,
+		Steps:     []stepTrace{},
+	}
+	if result == nil {
+		return resp
+	}
+
+	resp.Answer = result.Answer
+	resp.Result = result.Answer
+	resp.Plan = result.Plan
+	resp.Usage = result.Usage
+	for _, step := range result.Steps {
+		output, truncated := truncate(step.Output, maxTraceOutputRunes)
+		resp.Steps = append(resp.Steps, stepTrace{
+			Step:       step.Step,
+			ToolName:   step.ToolName,
+			Params:     step.Params,
+			Reason:     step.Reason,
+			Status:     step.Status,
+			DurationMs: step.DurationMs,
+			Output:     output,
+			Truncated:  truncated,
+			Error:      step.Error,
+			Attempts:   step.Attempts,
+			Fallback:   step.Fallback,
+		})
+	}
+	return resp
+}
+
+// truncate 按字符数截断文本，返回截断后的文本以及是否发生截断
+func truncate(s string, max int) (string, bool) {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s, false
+	}
+	return string(runes[:max]), true
+}
+
+// newRequestID 生成随机请求ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// healthResponse /health 响应体
+type healthResponse struct {
+	Status string            `json:"status"` // ok / degraded（有启用的工具初始化失败或健康检查失败）
+	Tools  []tool.ToolStatus `json:"tools"`
+}
+
+// newHealthHandler 创建健康检查处理器，返回服务整体状态及各工具状态
+func newHealthHandler(toolManager *tool.ToolManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := healthResponse{Status: "ok", Tools: toolManager.Status()}
+		for _, status := range resp.Tools {
+			if status.Status == tool.StatusDegraded || status.Status == tool.StatusUnhealthy {
+				resp.Status = "degraded"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+const (
+	healthCheckInterval = 30 * time.Second // 工具健康检查间隔
+	healthCheckTimeout  = 10 * time.Second // 单个工具健康检查的超时
+)
+
+// newLivenessHandler 创建存活探针处理器（/healthz）：进程能处理请求即视为存活，不受工具状态影响，避免因外部依赖故障而重启
+func newLivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}
+}
+
+// readyResponse /readyz 响应体
+type readyResponse struct {
+	Ready bool              `json:"ready"`
+	Tools []tool.ToolStatus `json:"tools"`
+}
+
+// newReadinessHandler 创建就绪探针处理器（/readyz）：服务未在关闭且所有必需工具（tools.<name>.required）均已就绪时返回200，否则返回503
+// 可选工具初始化失败或健康检查失败时服务降级运行，仍视为就绪，其状态只在响应中体现
+func newReadinessHandler(toolManager *tool.ToolManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := readyResponse{Ready: !toolManager.Draining(), Tools: toolManager.Status()}
+		for _, status := range resp.Tools {
+			if status.Required && status.Status != tool.StatusReady {
+				resp.Ready = false
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !resp.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// newMetricsHandler 创建指标处理器（/metrics），以Prometheus文本格式输出工具状态及各工具的限流状态
+func newMetricsHandler(toolManager *tool.ToolManager, limiter *tool.Limiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		metric := func(name, typ, help string) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		}
+
+		metric("mcp_tool_up", "gauge", "Whether the tool is initialized and healthy (1) or not (0).")
+		for _, status := range toolManager.Status() {
+			if status.Status == tool.StatusDisabled {
+				continue
+			}
+			up := 0
+			if status.Status == tool.StatusReady {
+				up = 1
+			}
+			fmt.Fprintf(&b, "mcp_tool_up{tool=%q,status=%q} %d\n", status.Name, status.Status, up)
+		}
+
+		stats := limiter.Stats()
+		metric("mcp_tool_limiter_in_flight", "gauge", "Tool calls currently holding a concurrency slot.")
+		for _, s := range stats {
+			fmt.Fprintf(&b, "mcp_tool_limiter_in_flight{tool=%q} %d\n", s.Tool, s.InFlight)
+		}
+		metric("mcp_tool_limiter_max_in_flight", "gauge", "Configured concurrency cap per tool (0 means unlimited).")
+		for _, s := range stats {
+			fmt.Fprintf(&b, "mcp_tool_limiter_max_in_flight{tool=%q} %d\n", s.Tool, s.MaxInFlight)
+		}
+		metric("mcp_tool_limiter_tokens", "gauge", "Tokens currently available in the tool rate limit bucket (-1 means unlimited).")
+		for _, s := range stats {
+			fmt.Fprintf(&b, "mcp_tool_limiter_tokens{tool=%q} %g\n", s.Tool, s.Tokens)
+		}
+		metric("mcp_tool_limiter_waiting", "gauge", "Tool calls currently queued by the limiter.")
+		for _, s := range stats {
+			fmt.Fprintf(&b, "mcp_tool_limiter_waiting{tool=%q} %d\n", s.Tool, s.Waiting)
+		}
+		metric("mcp_tool_limiter_clients", "gauge", "Clients (MCP sessions) tracked by the per-client limits.")
+		for _, s := range stats {
+			fmt.Fprintf(&b, "mcp_tool_limiter_clients{tool=%q} %d\n", s.Tool, s.Clients)
+		}
+		metric("mcp_tool_limiter_allowed_total", "counter", "Tool calls admitted by the limiter.")
+		for _, s := range stats {
+			fmt.Fprintf(&b, "mcp_tool_limiter_allowed_total{tool=%q} %d\n", s.Tool, s.Allowed)
+		}
+		metric("mcp_tool_limiter_rejected_total", "counter", "Tool calls rejected by the limiter, by scope (tool/client) and reason (rate/concurrency).")
+		for _, s := range stats {
+			keys := make([]string, 0, len(s.Rejected))
+			for key := range s.Rejected {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				scope, reason, _ := strings.Cut(key, "/")
+				fmt.Fprintf(&b, "mcp_tool_limiter_rejected_total{tool=%q,scope=%q,reason=%q} %d\n", s.Tool, scope, reason, s.Rejected[key])
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		io.WriteString(w, b.String())
+	}
+}
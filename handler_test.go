@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+	"mcp-server/coordinator"
+	"mcp-server/internal/tool"
+	"mcp-server/internal/tool/tooltest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	newManager := func(t *testing.T) *tool.ToolManager {
+		t.Helper()
+		m := tool.NewToolManager(tool.Dependencies{})
+		m.Register("ok", func(ctx context.Context, cfg any, deps tool.Dependencies) (tool.Tool, error) {
+			return tooltest.Echo("ok"), nil
+		})
+		m.Register("broken", func(ctx context.Context, cfg any, deps tool.Dependencies) (tool.Tool, error) {
+			return nil, errors.New("backend unavailable")
+		})
+		// broken初始化失败，以降级模式运行
+		m.InitTools(context.Background(), map[string]any{"ok": struct{}{}, "broken": struct{}{}})
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		required []string
+		drain    bool
+		want     int
+	}{
+		{name: "可选工具降级时仍就绪", want: http.StatusOK},
+		{name: "必需工具就绪", required: []string{"ok"}, want: http.StatusOK},
+		{name: "必需工具降级", required: []string{"ok", "broken"}, want: http.StatusServiceUnavailable},
+		{name: "关闭中", drain: true, want: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newManager(t)
+			m.SetRequired(tt.required)
+			if tt.drain {
+				if err := m.Drain(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			newReadinessHandler(m)(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			var resp readyResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Ready != (tt.want == http.StatusOK) || len(resp.Tools) != 2 {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
+
+// recordingSessionStore 记录被读取的会话ID，读取即失败以免继续调用模型
+type recordingSessionStore struct {
+	sessionIDs chan string
+}
+
+func (s *recordingSessionStore) History(ctx context.Context, sessionID string) ([]coordinator.Turn, error) {
+	s.sessionIDs <- sessionID
+	return nil, errors.New("unavailable")
+}
+
+func (s *recordingSessionStore) Append(ctx context.Context, sessionID string, turn coordinator.Turn) error {
+	return nil
+}
+
+func TestMessagesHandlerAcceptsOnlyLiveSessions(t *testing.T) {
+	a := &app{toolManager: tool.NewToolManager(tool.Dependencies{}), sessions: newLiveSessions()}
+	store := &recordingSessionStore{sessionIDs: make(chan string, 1)}
+	coor := coordinator.NewCoordinator(nil, a.toolManager, nil, coordinator.WithSessionStore(store))
+	svr := server.NewMCPServer("test", "1.0.0", server.WithHooks(a.sessions.hooks()))
+	opts := httpOptions{transports: map[string]bool{transportSSE: true}}
+	ts := httptest.NewServer(newMux(a, svr, coor, opts, "/", "", newRequestTracker(), zap.NewNop()))
+	defer ts.Close()
+
+	post := func(sessionID string) int {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/messages?sessionId="+sessionID, "application/json", strings.NewReader(`{"query":"hi"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post("forged"); got != http.StatusNotFound {
+		t.Errorf("unknown session: status = %d, want %d", got, http.StatusNotFound)
+	}
+
+	// 建立SSE会话，从endpoint事件中取得服务端签发的会话ID
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/sse", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	var sessionID string
+	for sessionID == "" && scanner.Scan() {
+		if _, after, ok := strings.Cut(scanner.Text(), "sessionId="); ok {
+			sessionID = after
+		}
+	}
+	if sessionID == "" {
+		t.Fatal("no session ID in endpoint event")
+	}
+
+	if got := post(sessionID); got != http.StatusInternalServerError {
+		t.Errorf("live session: status = %d, want %d", got, http.StatusInternalServerError)
+	}
+	if got := <-store.sessionIDs; got != sessionID {
+		t.Errorf("history read for %q, want %q", got, sessionID)
+	}
+
+	// 断开后会话注销，不再接受其ID
+	cancel()
+	resp.Body.Close()
+	deadline := time.Now().Add(time.Second)
+	for a.sessions.has(sessionID) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := post(sessionID); got != http.StatusNotFound {
+		t.Errorf("closed session: status = %d, want %d", got, http.StatusNotFound)
+	}
+}
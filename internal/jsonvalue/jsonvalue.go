@@ -0,0 +1,78 @@
+// Package jsonvalue 判断解码后的通用值（map[string]any、[]any及标量）对应的JSON Schema类型
+// 配置校验和计划参数校验共用；除encoding/json解码出的float64外，也接受YAML/TOML解码器产生的各种Go整数与浮点类型
+package jsonvalue
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Number 返回数值类型的值（任意Go整数或浮点类型）对应的float64
+func Number(value any) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// IsInteger 判断值是否为整数：Go整数类型，或没有小数部分的浮点数
+func IsInteger(value any) bool {
+	f, ok := Number(value)
+	return ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+}
+
+// MatchesType 判断值是否符合JSON Schema类型（string / number / integer / boolean / array / object / null），未知类型总是符合
+func MatchesType(value any, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := Number(value)
+		return ok
+	case "integer":
+		return IsInteger(value)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return true
+	}
+}
+
+// TypeName 返回值对应的JSON类型名，无法对应时返回Go类型名
+func TypeName(value any) string {
+	if value == nil {
+		return "null"
+	}
+	if _, ok := Number(value); ok {
+		return "number"
+	}
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
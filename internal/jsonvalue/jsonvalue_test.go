@@ -0,0 +1,60 @@
+package jsonvalue
+
+import "testing"
+
+func TestMatchesType(t *testing.T) {
+	tests := []struct {
+		value any
+		typ   string
+		want  bool
+	}{
+		{"a", "string", true},
+		{1.0, "string", false},
+		{1.5, "number", true},
+		{float32(1.5), "number", true},
+		{int64(3), "number", true},
+		{1.0, "integer", true},
+		{1.5, "integer", false},
+		{8080, "integer", true},
+		{int8(-1), "integer", true},
+		{int32(7), "integer", true},
+		{int64(7), "integer", true},
+		{uint(7), "integer", true},
+		{uint64(1 << 63), "integer", true},
+		{"1", "integer", false},
+		{true, "boolean", true},
+		{[]any{1.0}, "array", true},
+		{[]string{"a"}, "array", false},
+		{map[string]any{}, "object", true},
+		{nil, "null", true},
+		{nil, "string", false},
+		{"x", "custom", true},
+	}
+	for _, tt := range tests {
+		if got := MatchesType(tt.value, tt.typ); got != tt.want {
+			t.Errorf("MatchesType(%#v, %q) = %v, want %v", tt.value, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{nil, "null"},
+		{"a", "string"},
+		{1.0, "number"},
+		{int64(1), "number"},
+		{uint16(1), "number"},
+		{false, "boolean"},
+		{[]any{}, "array"},
+		{map[string]any{}, "object"},
+		{struct{}{}, "struct {}"},
+	}
+	for _, tt := range tests {
+		if got := TypeName(tt.value); got != tt.want {
+			t.Errorf("TypeName(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
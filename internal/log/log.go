@@ -7,12 +7,28 @@ import (
 
 var Logger *zap.Logger
 
+// Init 初始化日志，输出到stderr（stdout保留给stdio传输的JSON-RPC消息）
 func Init() {
+	InitWithOutput("stderr")
+}
+
+// InitWithOutput 初始化日志并输出到指定位置（stderr或文件路径），输出到文件时不使用颜色
+func InitWithOutput(output string) error {
 	config := zap.NewDevelopmentConfig()
 	config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	if output != "stderr" {
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	}
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	Logger, _ = config.Build()
+	config.OutputPaths = []string{output}
+	config.ErrorOutputPaths = []string{output}
+	logger, err := config.Build()
+	if err != nil {
+		return err
+	}
+	Logger = logger
 	defer Logger.Sync()
+	return nil
 }
 
 func GetLogger() *zap.Logger {
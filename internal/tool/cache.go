@@ -0,0 +1,398 @@
+package tool
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓存后端
+const (
+	CacheBackendMemory = "memory" // 进程内LRU
+	CacheBackendDisk   = "disk"   // 本地目录，重启后仍有效
+	CacheBackendNone   = "none"   // 关闭缓存
+)
+
+const (
+	defaultCacheTTL        = 5 * time.Minute
+	defaultCacheMaxEntries = 1000
+	defaultCacheDir        = ".cache/tool-results"
+)
+
+// CacheConfig 工具结果缓存配置，只缓存成功的结果
+type CacheConfig struct {
+	Backend    string `json:"backend" jsonschema:"enum=memory|disk|none"` // 缓存后端，默认memory
+	Dir        string `json:"dir"`                                        // disk后端的缓存目录（默认 .cache/tool-results）
+	TTL        string `json:"ttl" jsonschema:"format=duration"`           // 默认缓存时长（默认5m）
+	MaxEntries int    `json:"max_entries" jsonschema:"minimum=0"`         // 每个工具默认最多缓存的结果数（默认1000）
+}
+
+// CacheTTLer 可选接口：工具通过自身配置给出缓存时长（如 web_search 的 cache_duration），返回0时使用默认值
+type CacheTTLer interface {
+	CacheTTL() time.Duration
+}
+
+// CacheStore 缓存后端，每个工具使用独立的实例
+type CacheStore interface {
+	Get(key string) (*mcp.CallToolResult, bool)
+	Set(key string, result *mcp.CallToolResult, ttl time.Duration)
+}
+
+// ToolLookup 缓存中间件按公布名查找工具及其当前实例的版本（由ToolManager实现）
+type ToolLookup interface {
+	Lookup(name string) (Tool, bool)
+	Revision(name string) (string, bool)
+}
+
+// toolCachePolicy 单个工具解析后的缓存策略
+type toolCachePolicy struct {
+	cacheable  *bool // 为空时按工具描述的只读且幂等标注判断
+	ttl        time.Duration
+	maxEntries int
+}
+
+// resultCache 缓存中间件的状态：按工具懒创建缓存实例
+type resultCache struct {
+	cfg      CacheConfig
+	ttl      time.Duration
+	policies map[string]toolCachePolicy
+	tools    ToolLookup
+	logger   *zap.Logger
+	stores   map[string]CacheStore
+	mu       sync.Mutex
+}
+
+// Cache 结果缓存中间件：以工具公布名、实例版本加规范化后的参数为键，相同调用在有效期内直接返回缓存结果
+// 热加载以新配置替换工具实例后版本变化，之前缓存的结果不再命中（随后按容量淘汰或过期）
+// 工具是否可缓存由 middleware.tools.<name>.cacheable 决定，未配置时只缓存同时标注为只读和幂等的工具
+func Cache(cfg CacheConfig, perTool map[string]ToolCallConfig, tools ToolLookup, logger *zap.Logger) (Middleware, error) {
+	if cfg.Backend == CacheBackendNone {
+		return func(next Handler) Handler { return next }, nil
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = CacheBackendMemory
+	}
+	if cfg.Backend != CacheBackendMemory && cfg.Backend != CacheBackendDisk {
+		return nil, fmt.Errorf("unsupported cache backend: %s", cfg.Backend)
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = defaultCacheDir
+	}
+	ttl, err := parseTimeout("middleware.cache.ttl", cfg.TTL)
+	if err != nil {
+		return nil, err
+	}
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+
+	c := &resultCache{
+		cfg:      cfg,
+		ttl:      ttl,
+		policies: make(map[string]toolCachePolicy, len(perTool)),
+		tools:    tools,
+		logger:   logger,
+		stores:   make(map[string]CacheStore),
+	}
+	for name, toolCfg := range perTool {
+		p := toolCachePolicy{cacheable: toolCfg.Cacheable, maxEntries: toolCfg.CacheMaxEntries}
+		if p.ttl, err = parseTimeout("middleware.tools."+name+".cache_ttl", toolCfg.CacheTTL); err != nil {
+			return nil, err
+		}
+		c.policies[name] = p
+	}
+	return c.middleware, nil
+}
+
+// middleware 实现Middleware
+func (c *resultCache) middleware(next Handler) Handler {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.Params.Name
+		ttl, maxEntries, ok := c.policyFor(name)
+		if !ok {
+			return next(ctx, request)
+		}
+		revision, ok := c.tools.Revision(name)
+		if !ok {
+			return next(ctx, request)
+		}
+		key, err := cacheKey(name, revision, request.Params.Arguments)
+		if err != nil {
+			return next(ctx, request)
+		}
+
+		store := c.store(name, maxEntries)
+		if result, ok := store.Get(key); ok {
+			c.logger.Debug("命中工具结果缓存", zap.String("tool", name))
+			return result, nil
+		}
+		result, err := next(ctx, request)
+		if err == nil && result != nil && !result.IsError {
+			store.Set(key, result, ttl)
+		}
+		return result, err
+	}
+}
+
+// policyFor 返回工具的缓存时长与容量，工具不可缓存时返回false
+func (c *resultCache) policyFor(name string) (time.Duration, int, bool) {
+	t, ok := c.tools.Lookup(name)
+	if !ok {
+		return 0, 0, false
+	}
+	p := c.policies[name]
+	cacheable := isReadOnlyIdempotent(t.GetDescriptor())
+	if p.cacheable != nil {
+		cacheable = *p.cacheable
+	}
+	if !cacheable {
+		return 0, 0, false
+	}
+
+	ttl := p.ttl
+	if ttl == 0 {
+		if ttler, ok := t.(CacheTTLer); ok {
+			ttl = ttler.CacheTTL()
+		}
+	}
+	if ttl == 0 {
+		ttl = c.ttl
+	}
+	maxEntries := p.maxEntries
+	if maxEntries == 0 {
+		maxEntries = c.cfg.MaxEntries
+	}
+	if maxEntries == 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	return ttl, maxEntries, true
+}
+
+// store 返回工具的缓存实例（首次使用时创建）
+func (c *resultCache) store(name string, maxEntries int) CacheStore {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if store, ok := c.stores[name]; ok {
+		return store
+	}
+	var store CacheStore
+	if c.cfg.Backend == CacheBackendDisk {
+		store = newDiskCache(filepath.Join(c.cfg.Dir, name), maxEntries, c.logger)
+	} else {
+		store = newLRUCache(maxEntries)
+	}
+	c.stores[name] = store
+	return store
+}
+
+// isReadOnlyIdempotent 工具描述是否同时标注为只读和幂等
+func isReadOnlyIdempotent(descriptor *mcp.Tool) bool {
+	a := descriptor.Annotations
+	return a.ReadOnlyHint != nil && *a.ReadOnlyHint && a.IdempotentHint != nil && *a.IdempotentHint
+}
+
+// cacheKey 由工具名、实例版本和规范化后的参数生成缓存键：对象键排序，JSON字符串形式的参数先解析
+func cacheKey(name, revision string, arguments any) (string, error) {
+	if s, ok := arguments.(string); ok {
+		var parsed any
+		if err := json.Unmarshal([]byte(s), &parsed); err == nil {
+			arguments = parsed
+		}
+	}
+	canonical, err := json.Marshal(arguments) // map按键排序编码，结果与参数顺序无关
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(name+"\x00"+revision+"\x00"), canonical...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lruEntry LRU缓存条目
+type lruEntry struct {
+	key       string
+	result    *mcp.CallToolResult
+	expiresAt time.Time
+}
+
+// lruCache 进程内LRU缓存，超过容量时淘汰最久未使用的条目
+type lruCache struct {
+	maxEntries int
+	order      *list.List // 最近使用的在前
+	entries    map[string]*list.Element
+	mu         sync.Mutex
+}
+
+// newLRUCache 创建LRU缓存
+func newLRUCache(maxEntries int) *lruCache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get 实现CacheStore接口
+func (c *lruCache) Get(key string) (*mcp.CallToolResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.result, true
+}
+
+// Set 实现CacheStore接口
+func (c *lruCache) Set(key string, result *mcp.CallToolResult, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{key: key, result: result, expiresAt: time.Now().Add(ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// diskEntry 磁盘缓存文件内容
+type diskEntry struct {
+	ExpiresAt time.Time       `json:"expires_at"`
+	Result    json.RawMessage `json:"result"`
+}
+
+// diskCache 磁盘缓存：每个结果一个文件，超过容量时删除最早写入的文件
+type diskCache struct {
+	dir        string
+	maxEntries int
+	logger     *zap.Logger
+	mu         sync.Mutex
+}
+
+// newDiskCache 创建磁盘缓存
+func newDiskCache(dir string, maxEntries int, logger *zap.Logger) *diskCache {
+	return &diskCache{dir: dir, maxEntries: maxEntries, logger: logger}
+}
+
+// Get 实现CacheStore接口，读取失败或已过期时视为未命中
+func (c *diskCache) Get(key string) (*mcp.CallToolResult, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if time.Now().After(entry.ExpiresAt) {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	result, err := mcp.ParseCallToolResult(&entry.Result)
+	if err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+// Set 实现CacheStore接口，先写临时文件再重命名，避免并发读到半个文件
+func (c *diskCache) Set(key string, result *mcp.CallToolResult, ttl time.Duration) {
+	if err := c.write(key, result, ttl); err != nil {
+		c.logger.Warn("写入工具结果缓存失败", zap.String("dir", c.dir), zap.Error(err))
+		return
+	}
+	c.evict()
+}
+
+// write 写入单个缓存文件
+func (c *diskCache) write(key string, result *mcp.CallToolResult, ttl time.Duration) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(diskEntry{ExpiresAt: time.Now().Add(ttl), Result: raw})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// evict 缓存文件数超过容量时删除最早写入的文件
+func (c *diskCache) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type cacheFile struct {
+		name    string
+		modTime time.Time
+	}
+	var files []cacheFile
+	for _, e := range dirEntries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{name: e.Name(), modTime: info.ModTime()})
+	}
+	if len(files) <= c.maxEntries {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files[:len(files)-c.maxEntries] {
+		os.Remove(filepath.Join(c.dir, f.name))
+	}
+}
+
+// path 缓存键对应的文件路径
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
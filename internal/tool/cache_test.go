@@ -0,0 +1,150 @@
+package tool
+
+import (
+	"context"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	key := func(name, revision string, arguments any) string {
+		t.Helper()
+		k, err := cacheKey(name, revision, arguments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	base := key("search", "r1", map[string]any{"query": "go", "page": 1.0})
+	if got := key("search", "r1", map[string]any{"page": 1.0, "query": "go"}); got != base {
+		t.Error("key depends on argument order")
+	}
+	if got := key("search", "r1", `{"page":1,"query":"go"}`); got != base {
+		t.Error("JSON string arguments not canonicalized")
+	}
+	if got := key("search", "r2", map[string]any{"query": "go", "page": 1.0}); got == base {
+		t.Error("key does not depend on revision")
+	}
+	if got := key("other", "r1", map[string]any{"query": "go", "page": 1.0}); got == base {
+		t.Error("key does not depend on tool name")
+	}
+	if got := key("search", "r1", map[string]any{"query": "rust", "page": 1.0}); got == base {
+		t.Error("key does not depend on arguments")
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2)
+	c.Set("a", mcp.NewToolResultText("a"), time.Hour)
+	c.Set("b", mcp.NewToolResultText("b"), time.Hour)
+	c.Get("a") // a最近使用，超出容量时淘汰b
+	c.Set("c", mcp.NewToolResultText("c"), time.Hour)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %s evicted", key)
+		}
+	}
+
+	c.Set("expired", mcp.NewToolResultText("x"), -time.Second)
+	if _, ok := c.Get("expired"); ok {
+		t.Error("expired entry returned")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	c := newDiskCache(t.TempDir(), 2, zap.NewNop())
+	c.Set("a", mcp.NewToolResultText("cached"), time.Hour)
+	result, ok := c.Get("a")
+	if !ok || result.Content[0].(mcp.TextContent).Text != "cached" {
+		t.Fatalf("Get() = %v, %v", result, ok)
+	}
+
+	c.Set("expired", mcp.NewToolResultText("x"), -time.Second)
+	if _, ok := c.Get("expired"); ok {
+		t.Error("expired entry returned")
+	}
+	if _, err := os.Stat(c.path("expired")); !os.IsNotExist(err) {
+		t.Error("expired entry file not removed")
+	}
+
+	// 超过容量时删除最早写入的文件
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(c.path("a"), old, old)
+	c.Set("b", mcp.NewToolResultText("b"), time.Hour)
+	c.Set("c", mcp.NewToolResultText("c"), time.Hour)
+	if _, ok := c.Get("a"); ok {
+		t.Error("oldest entry not evicted")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %s evicted", key)
+		}
+	}
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	for _, backend := range []string{CacheBackendMemory, CacheBackendDisk} {
+		t.Run(backend, func(t *testing.T) {
+			f := &fakeFactory{name: "fake"}
+			m := newFakeManager(t, map[string]any{"fake": "v1"}, f)
+			cache, err := Cache(CacheConfig{Backend: backend, Dir: t.TempDir()}, nil, m, zap.NewNop())
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Use(cache)
+
+			callText(t, m, "fake")
+			if got := callText(t, m, "fake"); got != "v1" || f.instances[0].Executed.Load() != 1 {
+				t.Fatalf("second call = %q, executed %d times, want cached v1", got, f.instances[0].Executed.Load())
+			}
+
+			// 热加载以新配置替换实例后不再返回旧实例的缓存结果
+			if _, err := m.Reload(context.Background(), map[string]any{"fake": "v2"}); err != nil {
+				t.Fatal(err)
+			}
+			if got := callText(t, m, "fake"); got != "v2" {
+				t.Errorf("call after reload = %q, want v2", got)
+			}
+			callText(t, m, "fake")
+			if n := f.instances[1].Executed.Load(); n != 1 {
+				t.Errorf("new instance executed %d times, want 1", n)
+			}
+
+			// 移除后重新启用相同配置的工具，版本与之前相同，缓存仍然有效
+			if _, err := m.Reload(context.Background(), map[string]any{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.Reload(context.Background(), map[string]any{"fake": "v2"}); err != nil {
+				t.Fatal(err)
+			}
+			if got := callText(t, m, "fake"); got != "v2" || f.instances[2].Executed.Load() != 0 {
+				t.Errorf("call after re-enabling = %q, executed %d times", got, f.instances[2].Executed.Load())
+			}
+		})
+	}
+}
+
+func TestCacheMiddlewareSkipsUncacheable(t *testing.T) {
+	f := &fakeFactory{name: "fake"}
+	m := newFakeManager(t, map[string]any{"fake": "v1"}, f)
+	disabled := false
+	cache, err := Cache(CacheConfig{}, map[string]ToolCallConfig{"fake": {Cacheable: &disabled}}, m, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Use(cache)
+
+	callText(t, m, "fake")
+	callText(t, m, "fake")
+	if n := f.instances[0].Executed.Load(); n != 2 {
+		t.Errorf("executed %d times, want 2 for a tool with cacheable: false", n)
+	}
+}
@@ -17,6 +17,24 @@ type Tool interface {
 	Name() string
 }
 
+// 可选的生命周期接口，工具按需实现，由ToolManager驱动：
+// 构造后调用Start预热，运行期间周期性调用HealthCheck，服务关闭时调用Close
+
+// Starter 可选接口：构造完成后执行预热（如加载集合），失败时工具标记为降级
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// HealthChecker 可选接口：检查工具依赖的外部资源是否可用，失败时工具标记为不健康
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Closer 可选接口：持有外部资源（浏览器进程、数据库连接等）的工具实现该接口，服务关闭时由ToolManager调用
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
 // Dependencies 公共依赖集合（根据实际需求扩展）
 type Dependencies struct {
 	ChatModel *openai.ChatModel // 假设需要共享的OpenAI模型
@@ -25,3 +43,20 @@ type Dependencies struct {
 
 // Constructor 工具构造函数类型（用于依赖注入）
 type Constructor func(ctx context.Context, cfg any, deps Dependencies) (Tool, error)
+
+// 工具运行状态
+const (
+	StatusReady     = "ready"     // 已初始化，可用
+	StatusDisabled  = "disabled"  // 未配置或配置中enabled为false
+	StatusDegraded  = "degraded"  // 初始化失败，服务在缺少该工具的情况下继续运行
+	StatusUnhealthy = "unhealthy" // 已初始化，但最近一次健康检查失败
+)
+
+// ToolStatus 单个工具的运行状态
+type ToolStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`          // ready / disabled / degraded / unhealthy
+	Error  string `json:"error,omitempty"` // 最近一次初始化、重建或健康检查失败的原因
+
+	Required bool `json:"required,omitempty"` // 必需工具，不可用时服务未就绪
+}
@@ -0,0 +1,458 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	clientIdleTTL         = 10 * time.Minute // 客户端限流状态的空闲过期时间
+	clientEvictionEntries = 1024             // 单个工具跟踪的客户端数超过该值时才清理空闲客户端
+)
+
+// LimitConfig 限流配置：令牌桶限制调用速率，信号量限制同时进行的调用数
+// 同时作用于工具整体和每个客户端（MCP会话，或协调器处理查询时的会话ID），没有客户端标识的调用（如call-tool）只受工具整体限制
+type LimitConfig struct {
+	Rate                 float64 `json:"rate" jsonschema:"minimum=0"`                     // 工具每秒允许的调用数，0为不限制
+	Burst                int     `json:"burst" jsonschema:"minimum=0"`                    // 令牌桶容量（允许的突发调用数），默认为rate向上取整
+	MaxInFlight          int     `json:"max_in_flight" jsonschema:"minimum=0"`            // 工具同时进行的最大调用数，0为不限制
+	PerClientRate        float64 `json:"per_client_rate" jsonschema:"minimum=0"`          // 每个客户端每秒允许的调用数
+	PerClientBurst       int     `json:"per_client_burst" jsonschema:"minimum=0"`         // 每个客户端的令牌桶容量
+	PerClientMaxInFlight int     `json:"per_client_max_in_flight" jsonschema:"minimum=0"` // 每个客户端同时进行的最大调用数
+	QueueTimeout         string  `json:"queue_timeout" jsonschema:"format=duration"`      // 超限时排队等待的最长时间，为空时立即失败
+}
+
+// limitRule 单个作用域（工具或客户端）的限制
+type limitRule struct {
+	rate        float64
+	burst       int
+	maxInFlight int
+}
+
+// toolLimits 单个工具解析后的限流策略
+type toolLimits struct {
+	tool         limitRule
+	client       limitRule
+	queueTimeout time.Duration
+}
+
+// limited 是否有任何限制
+func (l toolLimits) limited() bool {
+	return l.tool != (limitRule{}) || l.client != (limitRule{})
+}
+
+// parseLimits 解析限流配置
+func parseLimits(key string, cfg LimitConfig) (toolLimits, error) {
+	queueTimeout, err := parseTimeout(key+".queue_timeout", cfg.QueueTimeout)
+	if err != nil {
+		return toolLimits{}, err
+	}
+	return toolLimits{
+		tool:         limitRule{rate: cfg.Rate, burst: cfg.Burst, maxInFlight: cfg.MaxInFlight},
+		client:       limitRule{rate: cfg.PerClientRate, burst: cfg.PerClientBurst, maxInFlight: cfg.PerClientMaxInFlight},
+		queueTimeout: queueTimeout,
+	}, nil
+}
+
+// tokenBucket 令牌桶，令牌数可以为负（表示已被排队的调用预订）
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// newTokenBucket 创建令牌桶，初始为满
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// refill 按时间补充令牌（调用方需持有锁）
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// reserve 预订一个令牌，返回可以执行前需等待的时间；需等待超过maxWait时不预订并返回false
+func (b *tokenBucket) reserve(maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// cancel 归还预订的令牌（排队被取消，或调用最终未被放行时）
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// available 当前可用的令牌数
+func (b *tokenBucket) available() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	return b.tokens
+}
+
+// limitError 超限错误
+type limitError struct {
+	tool   string
+	scope  string // tool / client
+	reason string // rate / concurrency
+	limit  string
+}
+
+// Error 实现error接口，作为MCP错误结果返回给客户端
+func (e *limitError) Error() string {
+	who := "工具 " + e.tool
+	if e.scope == limitScopeClient {
+		who = "当前客户端对工具 " + e.tool
+	}
+	if e.reason == limitReasonRate {
+		return fmt.Sprintf("%s 的调用过于频繁（限制为每秒%s次），请稍后重试", who, e.limit)
+	}
+	return fmt.Sprintf("%s 的并发调用数已达上限（%s），请稍后重试", who, e.limit)
+}
+
+// 限流作用域与原因（用于错误信息和指标标签）
+const (
+	limitScopeTool        = "tool"
+	limitScopeClient      = "client"
+	limitReasonRate       = "rate"
+	limitReasonConcurrent = "concurrency"
+)
+
+// gate 单个作用域的令牌桶与并发信号量，字段为空表示不限制
+type gate struct {
+	scope    string
+	rule     limitRule
+	bucket   *tokenBucket
+	sem      chan struct{}
+	lastUsed time.Time // 仅客户端作用域使用，受Limiter.mu保护
+}
+
+// newGate 按规则创建
+func newGate(scope string, rule limitRule) *gate {
+	g := &gate{scope: scope, rule: rule}
+	if rule.rate > 0 {
+		g.bucket = newTokenBucket(rule.rate, rule.burst)
+	}
+	if rule.maxInFlight > 0 {
+		g.sem = make(chan struct{}, rule.maxInFlight)
+	}
+	return g
+}
+
+// acquire 取得令牌和并发名额，超限时在deadline前排队（deadline为零值时立即失败）
+// 未能取得并发名额时归还已预订的令牌，被拒绝的调用不消耗速率名额
+func (g *gate) acquire(ctx context.Context, toolName string, deadline time.Time, waiting *atomic.Int64) error {
+	if err := g.reserveToken(ctx, toolName, deadline, waiting); err != nil {
+		return err
+	}
+	if err := g.acquireSlot(ctx, toolName, deadline, waiting); err != nil {
+		g.refund()
+		return err
+	}
+	return nil
+}
+
+// reserveToken 预订令牌并等待到可以执行，不限速时直接返回
+func (g *gate) reserveToken(ctx context.Context, toolName string, deadline time.Time, waiting *atomic.Int64) error {
+	if g.bucket != nil {
+		wait, ok := g.bucket.reserve(time.Until(deadline))
+		if !ok {
+			return &limitError{tool: toolName, scope: g.scope, reason: limitReasonRate, limit: formatRate(g.rule.rate)}
+		}
+		if wait > 0 {
+			waiting.Add(1)
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				waiting.Add(-1)
+				g.bucket.cancel()
+				return ctx.Err()
+			case <-timer.C:
+				waiting.Add(-1)
+			}
+		}
+	}
+	return nil
+}
+
+// acquireSlot 取得并发名额，不限并发时直接返回
+func (g *gate) acquireSlot(ctx context.Context, toolName string, deadline time.Time, waiting *atomic.Int64) error {
+	if g.sem == nil {
+		return nil
+	}
+	select {
+	case g.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	rejected := &limitError{tool: toolName, scope: g.scope, reason: limitReasonConcurrent, limit: fmt.Sprint(g.rule.maxInFlight)}
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return rejected
+	}
+	waiting.Add(1)
+	defer waiting.Add(-1)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case g.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return rejected
+	}
+}
+
+// release 归还并发名额
+func (g *gate) release() {
+	if g.sem != nil {
+		<-g.sem
+	}
+}
+
+// refund 归还已消耗的令牌（调用被后续的限制拒绝、未实际执行时）
+func (g *gate) refund() {
+	if g.bucket != nil {
+		g.bucket.cancel()
+	}
+}
+
+// inFlight 正在进行的调用数（仅在限制并发时可统计）
+func (g *gate) inFlight() int {
+	return len(g.sem)
+}
+
+// formatRate 格式化速率
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%g", rate)
+}
+
+// toolLimiter 单个工具的限流状态及统计
+type toolLimiter struct {
+	limits   toolLimits
+	tool     *gate
+	clients  map[string]*gate // 客户端标识 -> 客户端限流状态，受Limiter.mu保护
+	waiting  atomic.Int64
+	allowed  atomic.Int64
+	rejected sync.Map // "scope/reason" -> *atomic.Int64
+}
+
+// reject 记录一次被拒绝的调用
+func (t *toolLimiter) reject(err *limitError) {
+	counter, _ := t.rejected.LoadOrStore(err.scope+"/"+err.reason, new(atomic.Int64))
+	counter.(*atomic.Int64).Add(1)
+}
+
+// Limiter 按工具及客户端限制调用速率和并发数的中间件
+type Limiter struct {
+	defaults toolLimits
+	perTool  map[string]toolLimits
+	tools    map[string]*toolLimiter
+	mu       sync.Mutex
+}
+
+// NewLimiter 按配置创建限流器：middleware.limit 为每个工具的默认限制，middleware.tools.<name>.limit 整体覆盖
+func NewLimiter(cfg MiddlewareConfig) (*Limiter, error) {
+	defaults, err := parseLimits("middleware.limit", cfg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	l := &Limiter{
+		defaults: defaults,
+		perTool:  make(map[string]toolLimits),
+		tools:    make(map[string]*toolLimiter),
+	}
+	for name, toolCfg := range cfg.Tools {
+		if toolCfg.Limit == nil {
+			continue
+		}
+		if l.perTool[name], err = parseLimits("middleware.tools."+name+".limit", *toolCfg.Limit); err != nil {
+			return nil, err
+		}
+		// 单独配置了限制的工具在首次调用前就出现在指标中
+		l.forTool(name)
+	}
+	return l, nil
+}
+
+// Middleware 实现Middleware：超限时立即（或排队超时后）返回MCP错误结果
+func (l *Limiter) Middleware(next Handler) Handler {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.Params.Name
+		t := l.forTool(name)
+		if !t.limits.limited() {
+			t.allowed.Add(1)
+			return next(ctx, request)
+		}
+
+		var deadline time.Time
+		if t.limits.queueTimeout > 0 {
+			deadline = time.Now().Add(t.limits.queueTimeout)
+		}
+		// 先检查客户端限制，避免单个客户端占满工具整体的名额
+		gates := []*gate{t.tool}
+		if client := l.clientGate(t, clientID(ctx)); client != nil {
+			gates = []*gate{client, t.tool}
+		}
+		for i, g := range gates {
+			if err := g.acquire(ctx, name, deadline, &t.waiting); err != nil {
+				// 调用未被放行，之前的作用域（如客户端）归还并发名额和令牌
+				for _, acquired := range gates[:i] {
+					acquired.release()
+					acquired.refund()
+				}
+				if limitErr, ok := err.(*limitError); ok {
+					t.reject(limitErr)
+					return mcp.NewToolResultError(limitErr.Error()), nil
+				}
+				return nil, err
+			}
+		}
+		defer func() {
+			for _, g := range gates {
+				g.release()
+			}
+		}()
+
+		t.allowed.Add(1)
+		return next(ctx, request)
+	}
+}
+
+// forTool 返回工具的限流状态（首次调用时创建）
+func (l *Limiter) forTool(name string) *toolLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t, ok := l.tools[name]; ok {
+		return t
+	}
+	limits, ok := l.perTool[name]
+	if !ok {
+		limits = l.defaults
+	}
+	t := &toolLimiter{
+		limits:  limits,
+		tool:    newGate(limitScopeTool, limits.tool),
+		clients: make(map[string]*gate),
+	}
+	l.tools[name] = t
+	return t
+}
+
+// clientGate 返回客户端的限流状态，没有客户端标识或未配置客户端限制时返回nil
+func (l *Limiter) clientGate(t *toolLimiter, client string) *gate {
+	if client == "" || t.limits.client == (limitRule{}) {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	g, ok := t.clients[client]
+	if !ok {
+		if len(t.clients) >= clientEvictionEntries {
+			for id, idle := range t.clients {
+				if now.Sub(idle.lastUsed) > clientIdleTTL && idle.inFlight() == 0 {
+					delete(t.clients, id)
+				}
+			}
+		}
+		g = newGate(limitScopeClient, t.limits.client)
+		t.clients[client] = g
+	}
+	g.lastUsed = now
+	return g
+}
+
+// clientIDKey 上下文中客户端标识的键
+type clientIDKey struct{}
+
+// WithClientID 在上下文中设置发起工具调用的客户端标识（如 /messages 的 sessionId），用于按客户端限流
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, id)
+}
+
+// clientID 取客户端标识：优先使用WithClientID设置的标识，其次为MCP会话ID
+func clientID(ctx context.Context) string {
+	if id, ok := ctx.Value(clientIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// LimiterStats 单个工具的限流统计
+type LimiterStats struct {
+	Tool        string           `json:"tool"`
+	InFlight    int              `json:"in_flight"`         // 正在进行的调用数（仅限制并发时统计）
+	MaxInFlight int              `json:"max_in_flight"`     // 并发上限，0为不限制
+	Tokens      float64          `json:"tokens"`            // 当前可用令牌数，不限速时为-1
+	Waiting     int64            `json:"waiting"`           // 正在排队的调用数
+	Clients     int              `json:"clients"`           // 跟踪中的客户端数
+	Allowed     int64            `json:"allowed"`           // 通过限流的调用总数
+	Rejected    map[string]int64 `json:"rejected"`          // 被拒绝的调用数，键为 作用域/原因（如 client/rate）
+	Limited     bool             `json:"limited,omitempty"` // 是否配置了任何限制
+}
+
+// Stats 返回所有被调用过或单独配置了限制的工具的限流统计（按工具名排序）
+func (l *Limiter) Stats() []LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make([]LimiterStats, 0, len(l.tools))
+	for name, t := range l.tools {
+		s := LimiterStats{
+			Tool:        name,
+			InFlight:    t.tool.inFlight(),
+			MaxInFlight: t.limits.tool.maxInFlight,
+			Tokens:      -1,
+			Waiting:     t.waiting.Load(),
+			Clients:     len(t.clients),
+			Allowed:     t.allowed.Load(),
+			Rejected:    make(map[string]int64),
+			Limited:     t.limits.limited(),
+		}
+		if t.tool.bucket != nil {
+			s.Tokens = t.tool.bucket.available()
+		}
+		t.rejected.Range(func(key, value any) bool {
+			s.Rejected[key.(string)] = value.(*atomic.Int64).Load()
+			return true
+		})
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Tool < stats[j].Tool })
+	return stats
+}
@@ -0,0 +1,188 @@
+package tool
+
+import (
+	"context"
+	"github.com/mark3labs/mcp-go/mcp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newLimitedHandler 创建只对工具limited生效的限流器及经过限流的处理函数，处理函数开始时向started发送信号，release关闭前阻塞
+func newLimitedHandler(t *testing.T, cfg LimitConfig, release chan struct{}, started chan struct{}) (*Limiter, Handler) {
+	t.Helper()
+	l, err := NewLimiter(MiddlewareConfig{Tools: map[string]ToolCallConfig{"limited": {Limit: &cfg}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := l.Middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if started != nil {
+			started <- struct{}{}
+		}
+		if release != nil {
+			<-release
+		}
+		return mcp.NewToolResultText("ok"), nil
+	})
+	return l, h
+}
+
+// callLimited 以客户端client（为空时不带客户端标识）调用限流后的处理函数，返回被拒绝的原因（通过时为空）
+func callLimited(t *testing.T, h Handler, client string) string {
+	t.Helper()
+	ctx := context.Background()
+	if client != "" {
+		ctx = WithClientID(ctx, client)
+	}
+	var request mcp.CallToolRequest
+	request.Params.Name = "limited"
+	result, err := h(ctx, request)
+	if err != nil {
+		t.Fatalf("call error = %v", err)
+	}
+	if result.IsError {
+		return result.Content[0].(mcp.TextContent).Text
+	}
+	return ""
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(1, 2)
+	for i := 0; i < 2; i++ {
+		if wait, ok := b.reserve(0); !ok || wait != 0 {
+			t.Fatalf("reserve #%d = %v, %v, want immediate", i, wait, ok)
+		}
+	}
+	if _, ok := b.reserve(0); ok {
+		t.Error("reserve beyond burst succeeded without waiting")
+	}
+	if wait, ok := b.reserve(2 * time.Second); !ok || wait <= 0 {
+		t.Errorf("reserve with queue = %v, %v, want a positive wait", wait, ok)
+	}
+	b.cancel()
+	b.cancel()
+	b.cancel()
+	if tokens := b.available(); tokens > 2 {
+		t.Errorf("available() = %v, want at most burst", tokens)
+	}
+}
+
+func TestLimiterClientIDFromContext(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 4)
+	_, h := newLimitedHandler(t, LimitConfig{PerClientMaxInFlight: 1}, release, started)
+
+	done := make(chan string)
+	go func() { done <- callLimited(t, h, "c1") }()
+	<-started
+
+	if reason := callLimited(t, h, "c1"); !strings.Contains(reason, "当前客户端") {
+		t.Errorf("second call from c1 = %q, want client concurrency rejection", reason)
+	}
+	// 其他客户端及没有客户端标识的调用不受c1的限制
+	close(release)
+	if reason := callLimited(t, h, "c2"); reason != "" {
+		t.Errorf("call from c2 rejected: %s", reason)
+	}
+	if reason := callLimited(t, h, ""); reason != "" {
+		t.Errorf("call without client rejected: %s", reason)
+	}
+	if reason := <-done; reason != "" {
+		t.Errorf("first call from c1 rejected: %s", reason)
+	}
+}
+
+func TestLimiterRefundsClientTokenWhenToolRejects(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 4)
+	l, h := newLimitedHandler(t, LimitConfig{MaxInFlight: 1, PerClientRate: 0.001, PerClientBurst: 1}, release, started)
+
+	// c2占用工具唯一的并发名额
+	done := make(chan string)
+	go func() { done <- callLimited(t, h, "c2") }()
+	<-started
+
+	// c1因工具并发已满被拒绝，不应消耗c1的令牌
+	if reason := callLimited(t, h, "c1"); !strings.Contains(reason, "并发调用数已达上限") || strings.Contains(reason, "当前客户端") {
+		t.Fatalf("call from c1 = %q, want tool concurrency rejection", reason)
+	}
+	close(release)
+	if reason := <-done; reason != "" {
+		t.Fatalf("call from c2 rejected: %s", reason)
+	}
+	if reason := callLimited(t, h, "c1"); reason != "" {
+		t.Errorf("retry from c1 rejected: %s (token was not refunded)", reason)
+	}
+	// 令牌已用完，再次调用按客户端速率拒绝
+	if reason := callLimited(t, h, "c1"); !strings.Contains(reason, "调用过于频繁") {
+		t.Errorf("third call from c1 = %q, want client rate rejection", reason)
+	}
+
+	stats := l.Stats()[0]
+	if stats.Rejected["tool/concurrency"] != 1 || stats.Rejected["client/rate"] != 1 || stats.Allowed != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestLimiterConcurrentCalls(t *testing.T) {
+	l, err := NewLimiter(MiddlewareConfig{Limit: LimitConfig{MaxInFlight: 3, PerClientMaxInFlight: 2, QueueTimeout: "5s"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var current, peak atomic.Int32
+	clientCurrent := make(map[string]*atomic.Int32)
+	clientPeak := make(map[string]*atomic.Int32)
+	for _, client := range []string{"a", "b", "c"} {
+		clientCurrent[client], clientPeak[client] = new(atomic.Int32), new(atomic.Int32)
+	}
+	raise := func(peak *atomic.Int32, n int32) {
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				return
+			}
+		}
+	}
+	h := l.Middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		client := clientID(ctx)
+		raise(&peak, current.Add(1))
+		raise(clientPeak[client], clientCurrent[client].Add(1))
+		time.Sleep(time.Millisecond)
+		clientCurrent[client].Add(-1)
+		current.Add(-1)
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	var wg sync.WaitGroup
+	var rejected atomic.Int32
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(client string) {
+			defer wg.Done()
+			var request mcp.CallToolRequest
+			request.Params.Name = "shared"
+			result, err := h(WithClientID(context.Background(), client), request)
+			if err != nil || result.IsError {
+				rejected.Add(1)
+			}
+		}([]string{"a", "b", "c"}[i%3])
+	}
+	wg.Wait()
+
+	if n := rejected.Load(); n != 0 {
+		t.Errorf("%d calls rejected, want all queued and allowed", n)
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("peak concurrency = %d, want at most 3", p)
+	}
+	for client, p := range clientPeak {
+		if p.Load() > 2 {
+			t.Errorf("client %s peak concurrency = %d, want at most 2", client, p.Load())
+		}
+	}
+	if stats := l.Stats(); len(stats) != 1 || stats[0].Allowed != 60 || stats[0].InFlight != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
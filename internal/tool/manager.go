@@ -2,20 +2,50 @@ package tool
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // ToolManager 工具管理器
+// 工具以注册名（即配置 tools.<name> 的键）管理，同时按MCP描述中对外公布的名称建立索引，
+// 调用方可以使用公布名、注册名、Name()或配置的别名查找工具
 type ToolManager struct {
 	tools        map[string]Tool
+	names        map[string]string // 公布的MCP工具名 -> 注册名
+	aliases      map[string]string // 别名 -> 注册名（兼容旧名称，由配置 tools.<name>.aliases 设置）
 	constructors map[string]Constructor
+	configs      map[string]any    // 各工具当前实例所使用的配置（用于热加载时比对）
+	revisions    map[string]string // 各工具当前实例的版本（由注册名和配置得出，配置变化后随之变化）
+	installs     uint64            // 安装实例的次数（配置无法编码时用于生成版本）
+	failures     map[string]string // 最近一次初始化或重建失败的原因
+	unhealthy    map[string]string // 最近一次健康检查失败的原因（检查通过后清除）
+	required     map[string]bool   // 必需工具（不可用时服务未就绪）
+	server       *server.MCPServer // 已注册到的MCP服务器（热加载后需同步）
 	deps         Dependencies
+	middlewares  []Middleware               // 工具调用中间件，按添加顺序由外到内
+	calls        map[string]*sync.WaitGroup // 各工具当前实例正在执行的调用（实例被替换后用于等待其调用结束）
+	retiring     []chan struct{}            // 被替换或移除、等待关闭的旧实例（关闭后对应通道关闭）
+	inflight     sync.WaitGroup             // 正在执行的工具调用
+	closing      bool                       // 正在关闭，不再接受新的调用
 	mu           sync.RWMutex
 }
 
+// ErrShuttingDown 服务关闭期间拒绝新的工具调用
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// retireCloseTimeout 关闭被热加载替换或移除的旧实例的超时
+const retireCloseTimeout = 10 * time.Second
+
 // Mu 新增：暴露读锁（供协调器遍历工具）
 func (m *ToolManager) Mu() *sync.RWMutex {
 	return &m.mu
@@ -26,61 +56,572 @@ func (m *ToolManager) Tools() map[string]Tool {
 	return m.tools
 }
 
-// GetTool 新增：获取工具实例（原回答已包含）
+// GetTool 获取工具实例，名称解析规则同Lookup
 func (m *ToolManager) GetTool(name string) (Tool, error) {
+	tool, ok := m.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("tool %s not found", name)
+	}
+	return tool, nil
+}
+
+// Lookup 按公布的MCP工具名（模型/客户端看到的名称）查找工具，兼容别名、注册名和Name()
+func (m *ToolManager) Lookup(name string) (Tool, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.resolve(name)
+	if !ok {
+		return nil, false
+	}
+	return m.tools[key], true
+}
+
+// resolve 将名称解析为已初始化工具的注册名（调用方需持有锁）
+func (m *ToolManager) resolve(name string) (string, bool) {
+	if key, ok := m.aliases[name]; ok {
+		_, installed := m.tools[key]
+		return key, installed
+	}
+	if key, ok := m.names[name]; ok {
+		return key, true
+	}
+	if _, ok := m.tools[name]; ok {
+		return name, true
+	}
+	for key, tool := range m.tools {
+		if tool.Name() == name {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// SetAliases 按注册名设置各工具的别名（替换之前的全部别名），使旧名称继续解析到对应工具
+// 别名不能与其他工具的注册名或公布名冲突，同一别名不能属于多个工具；有冲突时保持原有别名不变，错误汇总返回
+func (m *ToolManager) SetAliases(aliases map[string][]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved := make(map[string]string)
+	var errs []string
+	for key, names := range aliases {
+		if _, ok := m.constructors[key]; !ok {
+			errs = append(errs, fmt.Sprintf("aliases of unknown tool %s", key))
+			continue
+		}
+		for _, alias := range names {
+			switch owner, ok := m.names[alias]; {
+			case alias == "":
+				errs = append(errs, fmt.Sprintf("tool %s has an empty alias", key))
+			case alias != key && m.constructors[alias] != nil:
+				errs = append(errs, fmt.Sprintf("alias %s of tool %s conflicts with registered tool %s", alias, key, alias))
+			case ok && owner != key:
+				errs = append(errs, fmt.Sprintf("alias %s of tool %s conflicts with the name of tool %s", alias, key, owner))
+			case resolved[alias] != "" && resolved[alias] != key:
+				errs = append(errs, fmt.Sprintf("alias %s is used by both tool %s and tool %s", alias, resolved[alias], key))
+			default:
+				resolved[alias] = key
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("invalid tool aliases: %s", strings.Join(errs, "; "))
+	}
+	m.aliases = resolved
+	return nil
+}
+
+// install 以注册名key安装工具实例并更新公布名索引（调用方需持有锁）
+// 公布名与其他工具的公布名、注册名或别名冲突时拒绝安装，返回被替换实例的公布名
+func (m *ToolManager) install(key string, tool Tool, cfg any) (string, error) {
+	name := tool.GetDescriptor().Name
+	if name == "" {
+		return "", fmt.Errorf("tool %s has an empty name", key)
+	}
+	if owner, ok := m.names[name]; ok && owner != key {
+		return "", fmt.Errorf("tool name %s is already used by tool %s", name, owner)
+	}
+	if _, ok := m.constructors[name]; ok && name != key {
+		return "", fmt.Errorf("tool name %s conflicts with registered tool %s", name, name)
+	}
+	if owner, ok := m.aliases[name]; ok && owner != key {
+		return "", fmt.Errorf("tool name %s conflicts with an alias of tool %s", name, owner)
+	}
+
+	previous := m.uninstall(key)
+	m.tools[key] = tool
+	m.names[name] = key
+	m.configs[key] = cfg
+	m.revisions[key] = m.revision(key, cfg)
+	m.calls[key] = &sync.WaitGroup{}
+	return previous, nil
+}
+
+// revision 计算工具实例的版本：配置能编码为JSON时为注册名和配置的摘要（重启后不变，可用于磁盘缓存），否则为安装序号（调用方需持有锁）
+func (m *ToolManager) revision(key string, cfg any) string {
+	m.installs++
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Sprintf("%s#%d", key, m.installs)
+	}
+	sum := sha256.Sum256(append([]byte(key+"\x00"), data...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Revision 返回工具当前实例的版本，名称解析规则同Lookup；热加载替换实例且配置变化后版本随之变化
+// 结果缓存以版本区分缓存键，旧实例缓存的结果不会在替换后返回
+func (m *ToolManager) Revision(name string) (string, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	tool, ok := m.tools[name]
+	key, ok := m.resolve(name)
 	if !ok {
+		return "", false
+	}
+	return m.revisions[key], true
+}
+
+// uninstall 移除注册名为key的工具实例，返回其公布名（调用方需持有锁）
+// 被移除的实例在其进行中的调用全部结束后关闭
+func (m *ToolManager) uninstall(key string) string {
+	tool, ok := m.tools[key]
+	if !ok {
+		return ""
+	}
+	name := tool.GetDescriptor().Name
+	m.retire(tool, m.calls[key])
+	delete(m.tools, key)
+	delete(m.names, name)
+	delete(m.configs, key)
+	delete(m.revisions, key)
+	delete(m.calls, key)
+	return name
+}
+
+// retire 在后台等待旧实例进行中的调用结束后关闭它，不阻塞热加载（调用方需持有锁）
+func (m *ToolManager) retire(tool Tool, calls *sync.WaitGroup) {
+	// 顺便清理已经关闭完成的记录
+	pending := m.retiring[:0]
+	for _, done := range m.retiring {
+		select {
+		case <-done:
+		default:
+			pending = append(pending, done)
+		}
+	}
+	done := make(chan struct{})
+	m.retiring = append(pending, done)
+
+	go func() {
+		defer close(done)
+		calls.Wait()
+		ctx, cancel := context.WithTimeout(context.Background(), retireCloseTimeout)
+		defer cancel()
+		closeTool(ctx, tool)
+	}()
+}
+
+// Use 添加工具调用中间件，先添加的位于外层
+func (m *ToolManager) Use(middlewares ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.middlewares = append(m.middlewares, middlewares...)
+}
+
+// Call 调用工具（MCP客户端与协调器的统一入口），经过所有中间件后执行
+// 记录正在执行的调用，以便关闭服务时等待其完成，以及热加载替换实例后在其调用结束时关闭旧实例
+func (m *ToolManager) Call(ctx context.Context, name string, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	m.mu.RLock()
+	if m.closing {
+		m.mu.RUnlock()
+		return nil, ErrShuttingDown
+	}
+	key, ok := m.resolve(name)
+	if !ok {
+		m.mu.RUnlock()
 		return nil, fmt.Errorf("tool %s not found", name)
 	}
-	return tool, nil
+	t, calls := m.tools[key], m.calls[key]
+	calls.Add(1)
+	m.inflight.Add(1)
+	middlewares := m.middlewares
+	m.mu.RUnlock()
+	defer m.inflight.Done()
+	defer calls.Done()
+
+	// 中间件按公布名区分工具，别名与注册名在此统一
+	request.Params.Name = t.GetDescriptor().Name
+	return Chain(t.Execute, middlewares...)(ctx, request)
+}
+
+// Drain 停止接受新的工具调用，并等待正在执行的调用完成，直到ctx结束
+func (m *ToolManager) Drain(ctx context.Context) error {
+	m.mu.Lock()
+	m.closing = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for in-flight tool calls: %w", ctx.Err())
+	}
+}
+
+// Close 关闭所有实现了Closer接口的工具，释放浏览器进程、数据库连接等资源，并等待热加载替换下的旧实例关闭完成，错误汇总返回
+func (m *ToolManager) Close(ctx context.Context) error {
+	m.mu.Lock()
+	tools := make(map[string]Tool, len(m.tools))
+	for name, t := range m.tools {
+		tools[name] = t
+	}
+	retiring := append([]chan struct{}(nil), m.retiring...)
+	m.mu.Unlock()
+
+	var errs []string
+	for name, t := range tools {
+		closer, ok := t.(Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("close tool %s: %v", name, err))
+		}
+	}
+	for _, done := range retiring {
+		select {
+		case <-done:
+			continue
+		case <-ctx.Done():
+		}
+		errs = append(errs, fmt.Sprintf("wait for replaced tools to close: %v", ctx.Err()))
+		break
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
 }
 
 // NewToolManager 创建工具管理器实例
 func NewToolManager(deps Dependencies) *ToolManager {
 	return &ToolManager{
 		tools:        make(map[string]Tool),
+		names:        make(map[string]string),
+		aliases:      make(map[string]string),
 		constructors: make(map[string]Constructor),
+		configs:      make(map[string]any),
+		revisions:    make(map[string]string),
+		failures:     make(map[string]string),
+		unhealthy:    make(map[string]string),
+		calls:        make(map[string]*sync.WaitGroup),
 		deps:         deps,
 	}
 }
 
-// Register 注册工具构造函数（启动时调用
-func (m *ToolManager) Register(name string, constructor Constructor) {
+// Register 注册工具构造函数（启动时调用），同名工具重复注册时返回错误
+func (m *ToolManager) Register(name string, constructor Constructor) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+
+	if _, ok := m.constructors[name]; ok {
+		return fmt.Errorf("tool %s is already registered", name)
+	}
 	m.constructors[name] = constructor
+	return nil
 }
 
-// InitTools 初始化所有注册的工具（从配置加载)
+// InitTools 初始化所有已启用的工具（从配置加载）
+// 没有配置的工具视为禁用；初始化失败的工具标记为降级，其余工具照常可用，失败信息汇总返回
 func (m *ToolManager) InitTools(ctx context.Context, toolCfgs map[string]any) error {
-	for toolName, constructor := range m.constructors {
+	m.mu.RLock()
+	constructors := make(map[string]Constructor, len(m.constructors))
+	for name, constructor := range m.constructors {
+		constructors[name] = constructor
+	}
+	m.mu.RUnlock()
+
+	var errs []string
+	for toolName, constructor := range constructors {
 		cfg, ok := toolCfgs[toolName]
 		if !ok {
-			return fmt.Errorf("missing config for tool: %s", toolName)
+			continue
+		}
+		tool, err := m.build(ctx, constructor, cfg)
+
+		m.mu.Lock()
+		if err == nil {
+			if _, err = m.install(toolName, tool, cfg); err != nil {
+				closeTool(ctx, tool)
+			}
 		}
-		tool, err := constructor(ctx, cfg, m.deps)
 		if err != nil {
-			return fmt.Errorf("failed to initialize tool %s: %v", toolName, err)
+			m.failures[toolName] = err.Error()
+			errs = append(errs, fmt.Sprintf("failed to initialize tool %s: %v", toolName, err))
+		} else {
+			delete(m.failures, toolName)
 		}
-		m.tools[toolName] = tool
+		m.mu.Unlock()
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("some tools are degraded: %s", strings.Join(errs, "; "))
 	}
 	return nil
 }
 
-// RegisterToServer 将所有工具注册到MCP服务器
-func (m *ToolManager) RegisterToServer(svr *server.MCPServer) {
+// build 构造工具实例，实现了Starter的工具随后执行预热，预热失败时关闭该实例
+func (m *ToolManager) build(ctx context.Context, constructor Constructor, cfg any) (Tool, error) {
+	tool, err := constructor(ctx, cfg, m.deps)
+	if err != nil {
+		return nil, err
+	}
+	if starter, ok := tool.(Starter); ok {
+		if err := starter.Start(ctx); err != nil {
+			closeTool(ctx, tool)
+			return nil, fmt.Errorf("start: %w", err)
+		}
+	}
+	return tool, nil
+}
+
+// closeTool 关闭未能投入使用的工具实例
+func closeTool(ctx context.Context, tool Tool) {
+	if closer, ok := tool.(Closer); ok {
+		closer.Close(ctx)
+	}
+}
+
+// Reload 按新配置重建配置发生变化的工具，并原子地替换旧实例；未变化的工具保持不动
+// 新配置中不再启用的工具会被移除，之前初始化失败的工具会重新尝试；被替换或移除的旧实例在其进行中的调用结束后关闭
+// 重建失败的工具继续使用旧实例，错误汇总返回；已注册到MCP服务器时会同步更新并通知客户端工具列表变化
+func (m *ToolManager) Reload(ctx context.Context, toolCfgs map[string]any) ([]string, error) {
+	m.mu.RLock()
+	constructors := make(map[string]Constructor, len(m.constructors))
+	for name, constructor := range m.constructors {
+		constructors[name] = constructor
+	}
+	oldCfgs := make(map[string]any, len(m.configs))
+	for name, cfg := range m.configs {
+		oldCfgs[name] = cfg
+	}
+	m.mu.RUnlock()
+
+	// 在锁外构建新实例，避免阻塞正在进行的调用
+	rebuilt := make(map[string]Tool)
+	failed := make(map[string]string)
+	var disabled []string
+	var errs []string
+	for toolName, constructor := range constructors {
+		cfg, ok := toolCfgs[toolName]
+		if !ok {
+			disabled = append(disabled, toolName)
+			continue
+		}
+		if old, ok := oldCfgs[toolName]; ok && reflect.DeepEqual(old, cfg) {
+			continue
+		}
+		tool, err := m.build(ctx, constructor, cfg)
+		if err != nil {
+			failed[toolName] = err.Error()
+			errs = append(errs, fmt.Sprintf("failed to rebuild tool %s: %v", toolName, err))
+			continue
+		}
+		rebuilt[toolName] = tool
+	}
+
+	changed := make([]string, 0, len(rebuilt))
+	var removed []string
+	m.mu.Lock()
+	for toolName, tool := range rebuilt {
+		previous, err := m.install(toolName, tool, toolCfgs[toolName])
+		if err != nil {
+			closeTool(ctx, tool)
+			delete(rebuilt, toolName)
+			failed[toolName] = err.Error()
+			errs = append(errs, fmt.Sprintf("failed to rebuild tool %s: %v", toolName, err))
+			continue
+		}
+		if previous != "" && previous != tool.GetDescriptor().Name {
+			removed = append(removed, previous)
+		}
+		delete(m.failures, toolName)
+		delete(m.unhealthy, toolName)
+		changed = append(changed, toolName)
+	}
+	for toolName, reason := range failed {
+		m.failures[toolName] = reason
+	}
+	for _, toolName := range disabled {
+		delete(m.failures, toolName)
+		delete(m.unhealthy, toolName)
+		name := m.uninstall(toolName)
+		if name == "" {
+			continue
+		}
+		removed = append(removed, name)
+		changed = append(changed, toolName)
+	}
+	svr := m.server
+	m.mu.Unlock()
+	sort.Strings(changed)
+
+	// 重新注册变化的工具，AddTools/DeleteTools会向已连接的客户端发送 notifications/tools/list_changed
+	if svr != nil && len(rebuilt) > 0 {
+		serverTools := make([]server.ServerTool, 0, len(rebuilt))
+		for _, toolName := range changed {
+			if tool, ok := rebuilt[toolName]; ok {
+				serverTools = append(serverTools, m.serverTool(tool))
+			}
+		}
+		svr.AddTools(serverTools...)
+	}
+	if svr != nil && len(removed) > 0 {
+		svr.DeleteTools(removed...)
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return changed, fmt.Errorf("reload tools failed: %s", strings.Join(errs, "; "))
+	}
+	return changed, nil
+}
+
+// SetRequired 设置必需工具（按注册名），替换之前的设置；热加载配置后需重新设置
+func (m *ToolManager) SetRequired(names []string) {
+	required := make(map[string]bool, len(names))
+	for _, name := range names {
+		required[name] = true
+	}
+	m.mu.Lock()
+	m.required = required
+	m.mu.Unlock()
+}
+
+// Draining 返回是否已开始关闭（不再接受新的调用）
+func (m *ToolManager) Draining() bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	return m.closing
+}
 
+// Status 返回所有已注册工具的运行状态（按名称排序）
+func (m *ToolManager) Status() []ToolStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]ToolStatus, 0, len(m.constructors))
+	for toolName := range m.constructors {
+		status := ToolStatus{Name: toolName, Error: m.failures[toolName], Required: m.required[toolName]}
+		switch _, ok := m.tools[toolName]; {
+		case ok && m.unhealthy[toolName] != "":
+			status.Status = StatusUnhealthy
+			status.Error = m.unhealthy[toolName]
+		case ok:
+			status.Status = StatusReady
+		case status.Error != "":
+			status.Status = StatusDegraded
+		default:
+			status.Status = StatusDisabled
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// CheckHealth 并发检查所有实现了HealthChecker的工具，单个检查最长timeout，结果反映在Status中
+func (m *ToolManager) CheckHealth(ctx context.Context, timeout time.Duration) {
+	m.mu.RLock()
+	checked := make(map[string]Tool)
+	for toolName, tool := range m.tools {
+		if _, ok := tool.(HealthChecker); ok {
+			checked[toolName] = tool
+		}
+	}
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	var resultMu sync.Mutex
+	results := make(map[string]error, len(checked))
+	for toolName, tool := range checked {
+		wg.Add(1)
+		go func(toolName string, checker HealthChecker) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			err := checker.HealthCheck(checkCtx)
+			resultMu.Lock()
+			results[toolName] = err
+			resultMu.Unlock()
+		}(toolName, tool.(HealthChecker))
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for toolName, err := range results {
+		// 检查期间实例可能已被热加载替换，只记录仍在使用的实例的结果
+		if current, ok := m.tools[toolName]; !ok || current != checked[toolName] {
+			continue
+		}
+		if err != nil {
+			m.unhealthy[toolName] = fmt.Sprintf("health check failed: %v", err)
+		} else {
+			delete(m.unhealthy, toolName)
+		}
+	}
+}
+
+// MonitorHealth 立即执行一次健康检查，之后每隔interval检查一次，直到ctx结束
+func (m *ToolManager) MonitorHealth(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.CheckHealth(ctx, timeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RegisterToServer 将所有工具以公布名注册到MCP服务器（一次性添加，只触发一次工具列表变化通知）
+func (m *ToolManager) RegisterToServer(svr *server.MCPServer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.server = svr
+	serverTools := make([]server.ServerTool, 0, len(m.tools))
 	for _, tool := range m.tools {
-		svr.AddTool(
-			*tool.GetDescriptor(),
-			func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-				return tool.Execute(ctx, request)
-			},
-		)
+		serverTools = append(serverTools, m.serverTool(tool))
+	}
+	sort.Slice(serverTools, func(i, j int) bool { return serverTools[i].Tool.Name < serverTools[j].Tool.Name })
+	svr.AddTools(serverTools...)
+}
+
+// serverTool 构造MCP服务器工具条目，处理器在调用时按公布名取当前实例，热加载替换后立即生效
+func (m *ToolManager) serverTool(tool Tool) server.ServerTool {
+	descriptor := *tool.GetDescriptor()
+	name := descriptor.Name
+	return server.ServerTool{
+		Tool: descriptor,
+		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			result, err := m.Call(ctx, name, request)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return result, nil
+		},
 	}
 }
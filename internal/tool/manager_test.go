@@ -0,0 +1,210 @@
+package tool
+
+import (
+	"context"
+	"github.com/mark3labs/mcp-go/mcp"
+	"mcp-server/internal/tool/tooltest"
+	"testing"
+	"time"
+)
+
+// fakeFactory 按配置构造假工具（返回构造时的配置，便于区分新旧实例）并记录所有构造出的实例
+type fakeFactory struct {
+	name      string
+	block     chan struct{}
+	started   chan struct{}
+	instances []*tooltest.Tool
+}
+
+func (f *fakeFactory) construct(ctx context.Context, cfg any, deps Dependencies) (Tool, error) {
+	t := &tooltest.Tool{ToolName: f.name, Output: cfg.(string), Block: f.block, Started: f.started}
+	f.instances = append(f.instances, t)
+	return t, nil
+}
+
+// newFakeManager 创建只注册了给定工具的管理器并按cfgs初始化
+func newFakeManager(t *testing.T, cfgs map[string]any, factories ...*fakeFactory) *ToolManager {
+	t.Helper()
+	m := NewToolManager(Dependencies{})
+	for _, f := range factories {
+		if err := m.Register(f.name, f.construct); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.InitTools(context.Background(), cfgs); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// callText 调用工具并返回结果文本
+func callText(t *testing.T, m *ToolManager, name string) string {
+	t.Helper()
+	result, err := m.Call(context.Background(), name, mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("Call(%s) error = %v", name, err)
+	}
+	return result.Content[0].(mcp.TextContent).Text
+}
+
+// waitClosed 等待实例被关闭
+func waitClosed(t *testing.T, tool *tooltest.Tool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for tool.Closed.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("tool was not closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReloadClosesReplacedInstanceAfterInflightCalls(t *testing.T) {
+	f := &fakeFactory{name: "fake", block: make(chan struct{}), started: make(chan struct{}, 1)}
+	m := newFakeManager(t, map[string]any{"fake": "v1"}, f)
+
+	// 旧实例上有一个进行中的调用
+	done := make(chan *mcp.CallToolResult)
+	go func() {
+		result, _ := m.Call(context.Background(), "fake", mcp.CallToolRequest{})
+		done <- result
+	}()
+	<-f.started
+
+	changed, err := m.Reload(context.Background(), map[string]any{"fake": "v2"})
+	if err != nil || len(changed) != 1 {
+		t.Fatalf("Reload() = %v, %v", changed, err)
+	}
+	old, current := f.instances[0], f.instances[1]
+
+	time.Sleep(20 * time.Millisecond)
+	if n := old.Closed.Load(); n != 0 {
+		t.Fatalf("old instance closed %d times while a call was in flight", n)
+	}
+
+	close(f.block)
+	if result := <-done; result == nil || result.Content[0].(mcp.TextContent).Text != "v1" {
+		t.Errorf("in-flight call result = %v, want v1", result)
+	}
+	waitClosed(t, old)
+	if got := callText(t, m, "fake"); got != "v2" {
+		t.Errorf("call after reload = %q, want v2", got)
+	}
+	if n := current.Closed.Load(); n != 0 {
+		t.Errorf("current instance closed %d times", n)
+	}
+
+	if err := m.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if old.Closed.Load() != 1 || current.Closed.Load() != 1 {
+		t.Errorf("Close counts: old = %d, current = %d, want 1 and 1", old.Closed.Load(), current.Closed.Load())
+	}
+}
+
+func TestReloadClosesDisabledAndKeepsUnchanged(t *testing.T) {
+	a := &fakeFactory{name: "a"}
+	b := &fakeFactory{name: "b"}
+	m := newFakeManager(t, map[string]any{"a": "v1", "b": "v1"}, a, b)
+
+	if _, err := m.Reload(context.Background(), map[string]any{"a": "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, b.instances[0])
+	if _, ok := m.Lookup("b"); ok {
+		t.Error("disabled tool still registered")
+	}
+	if len(a.instances) != 1 || a.instances[0].Closed.Load() != 0 {
+		t.Errorf("unchanged tool rebuilt or closed: instances = %d", len(a.instances))
+	}
+}
+
+func TestCloseWaitsForRetiringInstances(t *testing.T) {
+	f := &fakeFactory{name: "fake", block: make(chan struct{}), started: make(chan struct{}, 1)}
+	m := newFakeManager(t, map[string]any{"fake": "v1"}, f)
+
+	go m.Call(context.Background(), "fake", mcp.CallToolRequest{})
+	<-f.started
+	if _, err := m.Reload(context.Background(), map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧实例的调用未结束，Close在ctx结束时返回错误
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Close(ctx); err == nil {
+		t.Error("Close() error = nil, want timeout while a replaced instance is still in use")
+	}
+
+	close(f.block)
+	if err := m.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.instances[0].Closed.Load(); n != 1 {
+		t.Errorf("replaced instance closed %d times, want 1", n)
+	}
+}
+
+func TestSetAliases(t *testing.T) {
+	a := &fakeFactory{name: "a"}
+	b := &fakeFactory{name: "b"}
+	m := newFakeManager(t, map[string]any{"a": "v1", "b": "v1"}, a, b)
+
+	if err := m.SetAliases(map[string][]string{"a": {"old_a", "a"}}); err != nil {
+		t.Fatalf("SetAliases() error = %v", err)
+	}
+	if got := callText(t, m, "old_a"); got != "v1" {
+		t.Errorf("call via alias = %q", got)
+	}
+
+	conflicts := []map[string][]string{
+		{"a": {"b"}},             // 与其他工具的注册名冲突
+		{"a": {"x"}, "b": {"x"}}, // 同一别名属于多个工具
+		{"a": {""}},              // 空别名
+		{"missing": {"y"}},       // 未注册的工具
+	}
+	for _, aliases := range conflicts {
+		if err := m.SetAliases(aliases); err == nil {
+			t.Errorf("SetAliases(%v) error = nil, want conflict", aliases)
+		}
+	}
+	// 出错时保留原有别名
+	if _, ok := m.Lookup("old_a"); !ok {
+		t.Error("alias lost after a rejected update")
+	}
+
+	// 热加载移除工具后别名不再解析；替换别名后旧别名失效
+	if _, err := m.Reload(context.Background(), map[string]any{"b": "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Lookup("old_a"); ok {
+		t.Error("alias still resolves to a disabled tool")
+	}
+	if err := m.SetAliases(map[string][]string{"b": {"old_b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Lookup("old_a"); ok {
+		t.Error("replaced alias still resolves")
+	}
+	if got := callText(t, m, "old_b"); got != "v1" {
+		t.Errorf("call via alias = %q", got)
+	}
+}
+
+func TestInstallRejectsNameUsedAsAlias(t *testing.T) {
+	a := &fakeFactory{name: "a"}
+	b := &fakeFactory{name: "b"}
+	m := newFakeManager(t, map[string]any{"a": "v1"}, a, b)
+	if err := m.SetAliases(map[string][]string{"a": {"legacy"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// b的公布名与a的别名相同时拒绝安装
+	b.name = "legacy"
+	if _, err := m.Reload(context.Background(), map[string]any{"a": "v1", "b": "v1"}); err == nil {
+		t.Error("Reload() error = nil, want conflict with alias")
+	}
+	if tool, _ := m.Lookup("legacy"); tool != a.instances[0] {
+		t.Error("alias no longer resolves to tool a")
+	}
+}
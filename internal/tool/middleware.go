@@ -0,0 +1,175 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Handler 工具调用处理函数（与Tool.Execute签名一致）
+type Handler func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// Middleware 工具调用中间件，包装下一个处理函数以实现日志、超时、异常恢复等横切逻辑
+// 中间件中 request.Params.Name 为工具公布的MCP名称（已由ToolManager从别名、注册名规范化）
+type Middleware func(next Handler) Handler
+
+// Chain 将中间件依次包装到h上，第一个中间件位于最外层
+func Chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// MiddlewareConfig 内置中间件配置，对MCP客户端和协调器发起的工具调用同样生效
+type MiddlewareConfig struct {
+	Timeout string                    `json:"timeout" jsonschema:"format=duration"` // 单次工具调用的默认超时，为空不限制
+	Cache   CacheConfig               `json:"cache"`                                // 工具结果缓存
+	Limit   LimitConfig               `json:"limit"`                                // 每个工具默认的限流配置
+	Tools   map[string]ToolCallConfig `json:"tools"`                                // 按工具公布名（如 vector_search）覆盖
+}
+
+// ToolCallConfig 单个工具的调用配置
+type ToolCallConfig struct {
+	Timeout         string       `json:"timeout" jsonschema:"format=duration"`     // 覆盖默认超时
+	Cacheable       *bool        `json:"cacheable"`                                // 是否缓存结果，未配置时只缓存标注为只读且幂等的工具
+	CacheTTL        string       `json:"cache_ttl" jsonschema:"format=duration"`   // 覆盖缓存时长
+	CacheMaxEntries int          `json:"cache_max_entries" jsonschema:"minimum=0"` // 覆盖最多缓存的结果数
+	Limit           *LimitConfig `json:"limit"`                                    // 覆盖默认的限流配置（整体替换）
+}
+
+// Middlewares 按配置创建内置中间件：调用日志（最外层）、异常恢复、结果缓存、限流、超时
+// tools 用于缓存中间件按名称取工具（判断是否可缓存、工具自身的缓存时长及实例版本）；limiter为空时不限流
+// 缓存位于限流之前，命中缓存的调用不消耗限流名额
+func (c MiddlewareConfig) Middlewares(logger *zap.Logger, tools ToolLookup, limiter *Limiter) ([]Middleware, error) {
+	defaultTimeout, err := parseTimeout("middleware.timeout", c.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	timeouts := make(map[string]time.Duration, len(c.Tools))
+	for name, toolCfg := range c.Tools {
+		if toolCfg.Timeout == "" {
+			continue
+		}
+		if timeouts[name], err = parseTimeout("middleware.tools."+name+".timeout", toolCfg.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	cache, err := Cache(c.Cache, c.Tools, tools, logger)
+	if err != nil {
+		return nil, err
+	}
+	middlewares := []Middleware{
+		Logging(logger),
+		Recovery(logger),
+		cache,
+	}
+	if limiter != nil {
+		middlewares = append(middlewares, limiter.Middleware)
+	}
+	return append(middlewares, Timeout(defaultTimeout, timeouts)), nil
+}
+
+// parseTimeout 解析超时配置，为空时返回0（不限制）
+func parseTimeout(key, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return d, nil
+}
+
+// Recovery 捕获工具执行中的panic，转换为MCP错误结果，避免单个工具拖垮整个服务
+func Recovery(logger *zap.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (result *mcp.CallToolResult, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error("工具执行异常", zap.String("tool", request.Params.Name),
+						zap.Any("panic", r), zap.Stack("stack"))
+					result, err = mcp.NewToolResultError(fmt.Sprintf("工具执行异常: %v", r)), nil
+				}
+			}()
+			return next(ctx, request)
+		}
+	}
+}
+
+// Logging 记录每次工具调用的结构化日志（工具名、耗时、是否出错）
+func Logging(logger *zap.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, request)
+			fields := []zap.Field{
+				zap.String("tool", request.Params.Name),
+				zap.Duration("duration", time.Since(start)),
+				zap.Bool("is_error", result != nil && result.IsError),
+			}
+			switch {
+			case err != nil:
+				logger.Warn("工具调用失败", append(fields, zap.Error(err))...)
+			case result != nil && result.IsError:
+				logger.Warn("工具返回错误", fields...)
+			default:
+				logger.Info("工具调用完成", fields...)
+			}
+			return result, err
+		}
+	}
+}
+
+// Timeout 为每次调用设置超时，perTool按工具公布名覆盖defaultTimeout，超时为0时不限制
+// 工具在另一个goroutine中执行，超时即返回MCP错误结果，不等待不响应ctx的工具返回；工具中的panic转交调用方（由Recovery处理）
+func Timeout(defaultTimeout time.Duration, perTool map[string]time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			timeout := defaultTimeout
+			if d, ok := perTool[request.Params.Name]; ok {
+				timeout = d
+			}
+			if timeout <= 0 {
+				return next(ctx, request)
+			}
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			done := make(chan callResult, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						done <- callResult{panicValue: r}
+					}
+				}()
+				result, err := next(ctx, request)
+				done <- callResult{result: result, err: err}
+			}()
+
+			select {
+			case r := <-done:
+				if r.panicValue != nil {
+					panic(r.panicValue)
+				}
+				return r.result, r.err
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return mcp.NewToolResultError(fmt.Sprintf("工具 %s 调用超时（%s）", request.Params.Name, timeout)), nil
+				}
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// callResult 在goroutine中执行的工具调用结果
+type callResult struct {
+	result     *mcp.CallToolResult
+	err        error
+	panicValue any
+}
@@ -0,0 +1,164 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRequest 创建调用指定工具的请求
+func newRequest(name string) mcp.CallToolRequest {
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	return request
+}
+
+// resultText 返回结果中的第一段文本
+func resultText(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+	if result == nil || len(result.Content) == 0 {
+		t.Fatalf("result = %+v, want text content", result)
+	}
+	return result.Content[0].(mcp.TextContent).Text
+}
+
+func TestTimeout(t *testing.T) {
+	// stuck 不响应ctx，模拟卡住的工具
+	stuck := func(release chan struct{}) Handler {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			<-release
+			return mcp.NewToolResultText("late"), nil
+		}
+	}
+	quick := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return mcp.NewToolResultText("no deadline"), nil
+		}
+		return mcp.NewToolResultText("ok"), nil
+	}
+
+	tests := []struct {
+		name     string
+		tool     string
+		stuck    bool
+		wantText string
+		wantErr  bool
+	}{
+		{name: "按时完成", tool: "fast", wantText: "ok"},
+		{name: "超时返回错误结果", tool: "fast", stuck: true, wantText: "调用超时", wantErr: true},
+		{name: "按工具覆盖超时", tool: "slow", stuck: true, wantText: "调用超时", wantErr: true},
+		{name: "超时为0时不限制", tool: "unlimited", wantText: "no deadline"},
+	}
+	perTool := map[string]time.Duration{"slow": 20 * time.Millisecond, "unlimited": 0}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			defer close(release)
+			next := Handler(quick)
+			if tt.stuck {
+				next = stuck(release)
+			}
+			h := Timeout(50*time.Millisecond, perTool)(next)
+
+			start := time.Now()
+			result, err := h(context.Background(), newRequest(tt.tool))
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("call took %v, want it to return at the deadline", elapsed)
+			}
+			if result.IsError != tt.wantErr || !strings.Contains(resultText(t, result), tt.wantText) {
+				t.Errorf("result = %q (is_error %v), want %q (is_error %v)", resultText(t, result), result.IsError, tt.wantText, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimeoutParentCancelled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	h := Timeout(time.Minute, nil)(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-release
+		return mcp.NewToolResultText("late"), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h(ctx, newRequest("fast")); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	panicking := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		panic("boom")
+	}
+
+	tests := []struct {
+		name        string
+		middlewares []Middleware
+	}{
+		{name: "直接panic", middlewares: []Middleware{Recovery(zap.NewNop())}},
+		{name: "超时中间件内的panic", middlewares: []Middleware{Recovery(zap.NewNop()), Timeout(time.Second, nil)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Chain(panicking, tt.middlewares...)(context.Background(), newRequest("broken"))
+			if err != nil {
+				t.Fatalf("error = %v, want panic converted to a result", err)
+			}
+			if !result.IsError || !strings.Contains(resultText(t, result), "boom") {
+				t.Errorf("result = %q (is_error %v), want error result mentioning the panic", resultText(t, result), result.IsError)
+			}
+		})
+	}
+}
+
+func TestLogging(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    *mcp.CallToolResult
+		err       error
+		wantLevel zapcore.Level
+		wantMsg   string
+	}{
+		{name: "成功", result: mcp.NewToolResultText("ok"), wantLevel: zapcore.InfoLevel, wantMsg: "工具调用完成"},
+		{name: "错误结果", result: mcp.NewToolResultError("bad"), wantLevel: zapcore.WarnLevel, wantMsg: "工具返回错误"},
+		{name: "调用失败", err: errors.New("down"), wantLevel: zapcore.WarnLevel, wantMsg: "工具调用失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			h := Logging(zap.New(core))(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				return tt.result, tt.err
+			})
+
+			result, err := h(context.Background(), newRequest("search"))
+			if result != tt.result || err != tt.err {
+				t.Errorf("Logging changed the call result: %v, %v", result, err)
+			}
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("got %d log entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMsg {
+				t.Errorf("log = %v %q, want %v %q", entry.Level, entry.Message, tt.wantLevel, tt.wantMsg)
+			}
+			fields := entry.ContextMap()
+			if fields["tool"] != "search" || fields["is_error"] != (tt.result != nil && tt.result.IsError) {
+				t.Errorf("log fields = %v", fields)
+			}
+			if _, ok := fields["duration"]; !ok {
+				t.Error("log entry has no duration")
+			}
+		})
+	}
+}
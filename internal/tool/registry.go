@@ -0,0 +1,71 @@
+package tool
+
+import (
+	"sort"
+	"sync"
+)
+
+// ConfigFactory 返回工具配置结构的新实例（指针），配置包据此解析 tools.<name>
+type ConfigFactory func() any
+
+// builtin 内置工具的构造函数及配置工厂
+type builtin struct {
+	constructor Constructor
+	newConfig   ConfigFactory
+}
+
+// builtins 内置工具注册表（由各工具包在init中注册）
+var (
+	builtins   = make(map[string]builtin)
+	builtinsMu sync.RWMutex
+)
+
+// RegisterBuiltin 注册内置工具的构造函数和配置工厂，重复注册同名工具时panic
+func RegisterBuiltin(name string, constructor Constructor, newConfig ConfigFactory) {
+	builtinsMu.Lock()
+	defer builtinsMu.Unlock()
+
+	if _, ok := builtins[name]; ok {
+		panic("tool: duplicate builtin registration for " + name)
+	}
+	builtins[name] = builtin{constructor: constructor, newConfig: newConfig}
+}
+
+// NewBuiltinConfig 创建内置工具的空配置实例，工具未注册时返回false
+func NewBuiltinConfig(name string) (any, bool) {
+	builtinsMu.RLock()
+	defer builtinsMu.RUnlock()
+
+	b, ok := builtins[name]
+	if !ok {
+		return nil, false
+	}
+	return b.newConfig(), true
+}
+
+// BuiltinNames 返回所有已注册的内置工具名（按名称排序）
+func BuiltinNames() []string {
+	builtinsMu.RLock()
+	defer builtinsMu.RUnlock()
+
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterBuiltins 将所有内置工具构造函数注册到工具管理器
+// 是否真正启用由配置中的 tools.<name> 决定，见 InitTools
+func (m *ToolManager) RegisterBuiltins() error {
+	builtinsMu.RLock()
+	defer builtinsMu.RUnlock()
+
+	for name, b := range builtins {
+		if err := m.Register(name, b.constructor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
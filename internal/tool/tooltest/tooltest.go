@@ -0,0 +1,80 @@
+// Package tooltest 提供工具管理器、中间件与协调器测试共用的假工具
+package tooltest
+
+import (
+	"context"
+	"fmt"
+	"github.com/mark3labs/mcp-go/mcp"
+	"sync/atomic"
+)
+
+// Tool 测试用工具：执行逻辑由Run决定（为空时返回Output），记录执行与Close次数
+// 描述中标注为只读且幂等（结果可被缓存中间件缓存）
+type Tool struct {
+	ToolName string                                                                 // 工具名
+	Params   []mcp.ToolOption                                                       // 参数定义，为空时只有字符串参数query
+	Run      func(ctx context.Context, args map[string]interface{}) (string, error) // 执行逻辑，返回错误时作为MCP错误结果
+	Output   string                                                                 // Run为空时返回的文本
+	Block    chan struct{}                                                          // 非空时Execute在其关闭前阻塞
+	Started  chan struct{}                                                          // 非空时Execute开始时发送信号
+
+	Executed atomic.Int32 // Execute调用次数
+	Closed   atomic.Int32 // Close调用次数
+}
+
+// New 创建执行逻辑为run的工具
+func New(name string, run func(ctx context.Context, args map[string]interface{}) (string, error)) *Tool {
+	return &Tool{ToolName: name, Run: run}
+}
+
+// Echo 创建返回query参数的工具
+func Echo(name string) *Tool {
+	return New(name, func(ctx context.Context, args map[string]interface{}) (string, error) {
+		return fmt.Sprint(args["query"]), nil
+	})
+}
+
+// GetDescriptor 实现tool.Tool接口
+func (t *Tool) GetDescriptor() *mcp.Tool {
+	params := t.Params
+	if params == nil {
+		params = []mcp.ToolOption{mcp.WithString("query")}
+	}
+	opts := append([]mcp.ToolOption{
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithIdempotentHintAnnotation(true),
+	}, params...)
+	descriptor := mcp.NewTool(t.ToolName, opts...)
+	return &descriptor
+}
+
+// Execute 实现tool.Tool接口
+func (t *Tool) Execute(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	t.Executed.Add(1)
+	if t.Started != nil {
+		t.Started <- struct{}{}
+	}
+	if t.Block != nil {
+		<-t.Block
+	}
+	if t.Run == nil {
+		return mcp.NewToolResultText(t.Output), nil
+	}
+	args, _ := request.Params.Arguments.(map[string]interface{})
+	text, err := t.Run(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcp.NewToolResultText(text), nil
+}
+
+// Name 实现tool.Tool接口
+func (t *Tool) Name() string {
+	return t.ToolName
+}
+
+// Close 实现tool.Closer接口
+func (t *Tool) Close(ctx context.Context) error {
+	t.Closed.Add(1)
+	return nil
+}
@@ -16,6 +16,11 @@ type BrowserTool struct {
 	cfg  *Config
 }
 
+// 注册为内置工具，是否启用由配置 tools.browseruse 决定
+func init() {
+	tool.RegisterBuiltin("browseruse", NewBrowseruseTool, func() any { return &Config{} })
+}
+
 // Config 浏览器工具配置
 type Config struct {
 	Headless          bool     `json:"headless"`
@@ -47,6 +52,31 @@ func NewBrowseruseTool(ctx context.Context, cfg any, deps tool.Dependencies) (to
 
 }
 
+// HealthCheck 实现tool.HealthChecker接口，通过读取当前页面状态确认浏览器进程仍可响应
+func (t *BrowserTool) HealthCheck(ctx context.Context) error {
+	// GetCurrentState不接收ctx，在协程中执行以便超时返回
+	done := make(chan error, 1)
+	go func() {
+		_, err := t.impl.GetCurrentState()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("browser is not responding: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("browser is not responding: %w", ctx.Err())
+	}
+}
+
+// Close 实现tool.Closer接口，关闭浏览器进程
+func (t *BrowserTool) Close(ctx context.Context) error {
+	t.impl.Cleanup()
+	return nil
+}
+
 // GetDescriptor 实现工具接口
 func (t *BrowserTool) GetDescriptor() *mcp.Tool {
 	tool := mcp.NewTool("browseruse",
@@ -9,14 +9,21 @@ import (
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 	"mcp-server/internal/tool"
+	"strings"
 )
 
 // MilvusTool 实现MCP工具接口
 type MilvusTool struct {
+	cli       client.Client
 	retriever *milvus.Retriever
 	cfg       *Config
 }
 
+// 注册为内置工具，是否启用由配置 tools.milvus 决定
+func init() {
+	tool.RegisterBuiltin("milvus", NewMilvusTool, func() any { return &Config{} })
+}
+
 // Config 工具配置
 type Config struct {
 	Address           string  `json:"address"`
@@ -24,12 +31,12 @@ type Config struct {
 	ModelName         string  `json:"model_name"`
 	Collection        string  `json:"collection"`
 	VectorField       string  `json:"vector_field"`
-	TopK              int     `json:"top_k"`
+	TopK              int     `json:"top_k" jsonschema:"minimum=1"`
 	ScoreThreshold    float64 `json:"score_threshold"`
-	MetricType        string  `json:"metric_type"` // 添加度量类型字段
+	MetricType        string  `json:"metric_type" jsonschema:"enum=L2|IP|HAMMING|JACCARD"` // 添加度量类型字段
 }
 
-func NewMilvusTool(ctx context.Context, cfg any, deps tool.Dependencies) (tool.Tool, error) {
+func NewMilvusTool(ctx context.Context, cfg any, deps tool.Dependencies) (_ tool.Tool, err error) {
 	config, ok := cfg.(*Config)
 	if !ok {
 		return nil, fmt.Errorf("invalid config type for milvus tool")
@@ -53,6 +60,12 @@ func NewMilvusTool(ctx context.Context, cfg any, deps tool.Dependencies) (tool.T
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to milvus: %v", err)
 	}
+	// 初始化失败时关闭连接
+	defer func() {
+		if err != nil {
+			cli.Close()
+		}
+	}()
 
 	emb := NewSiliconFlowEmbedder(config.SiliconFlowAPIKey, config.ModelName)
 	// 检查集合是否存在
@@ -63,19 +76,7 @@ func NewMilvusTool(ctx context.Context, cfg any, deps tool.Dependencies) (tool.T
 	if !exists {
 		return nil, fmt.Errorf("collection %s does not exist", config.Collection)
 	}
-	// 加载集合(异步加载)
-	err = cli.LoadCollection(ctx, config.Collection, false)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load collection: %v", err)
-	}
-	// 检查加载状态
-	loadState, err := cli.GetLoadState(ctx, config.Collection, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get load state: %v", err)
-	}
-	if loadState != entity.LoadStateLoaded {
-		return nil, fmt.Errorf("collection %s is not loaded, current state: %v", config.Collection, loadState)
-	}
+	// 集合的加载在Start中完成
 
 	retriever, err := milvus.NewRetriever(ctx, &milvus.RetrieverConfig{
 		Client:         cli,
@@ -102,16 +103,58 @@ func NewMilvusTool(ctx context.Context, cfg any, deps tool.Dependencies) (tool.T
 		return nil, fmt.Errorf("failed to create milvus retriever: %v", err)
 	}
 	return &MilvusTool{
+		cli:       cli,
 		retriever: retriever,
 		cfg:       config,
 	}, nil
 }
 
+// Start 实现tool.Starter接口，加载集合并等待加载完成
+func (t *MilvusTool) Start(ctx context.Context) error {
+	if err := t.cli.LoadCollection(ctx, t.cfg.Collection, false); err != nil {
+		return fmt.Errorf("failed to load collection: %v", err)
+	}
+	return t.checkLoaded(ctx)
+}
+
+// HealthCheck 实现tool.HealthChecker接口，检查Milvus服务状态及集合是否仍处于已加载状态
+func (t *MilvusTool) HealthCheck(ctx context.Context) error {
+	state, err := t.cli.CheckHealth(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check milvus health: %v", err)
+	}
+	if !state.IsHealthy {
+		return fmt.Errorf("milvus is unhealthy: %s", strings.Join(state.Reasons, "; "))
+	}
+	return t.checkLoaded(ctx)
+}
+
+// checkLoaded 检查集合加载状态
+func (t *MilvusTool) checkLoaded(ctx context.Context) error {
+	loadState, err := t.cli.GetLoadState(ctx, t.cfg.Collection, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get load state: %v", err)
+	}
+	if loadState != entity.LoadStateLoaded {
+		return fmt.Errorf("collection %s is not loaded, current state: %v", t.cfg.Collection, loadState)
+	}
+	return nil
+}
+
+// Close 实现tool.Closer接口，关闭Milvus连接
+func (t *MilvusTool) Close(ctx context.Context) error {
+	return t.cli.Close()
+}
+
 // GetDescriptor 实现工具接口
 func (t *MilvusTool) GetDescriptor() *mcp.Tool {
 	tool := mcp.NewTool("vector_search",
 		mcp.WithDescription("搜索模块功能及其对应的入口URL（如门票、酒店预订等），输入用户问题即可返回匹配的功能入口URL"),
 		mcp.WithString("query", mcp.Required(), mcp.Description("检索查询文本，系统将分析意图并匹配最相关的资源")),
+		// 只读且幂等，结果可由缓存中间件缓存
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
 	)
 	return &tool
 }
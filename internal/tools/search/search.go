@@ -11,22 +11,33 @@ import (
 	"go.uber.org/zap"
 	"mcp-server/internal/log"
 	tol "mcp-server/internal/tool"
+	"net/http"
+	"net/url"
 	"time"
 )
 
 // SearchTool 搜索工具实现
 type SearchTool struct {
-	impl tool.InvokableTool
-	cfg  *Config
+	impl  tool.InvokableTool
+	cfg   *Config
+	probe *http.Client // 健康检查用的HTTP客户端（与搜索使用同一代理）
 }
 
-var logger = log.GetLogger()
+// 注册为内置工具，是否启用由配置 tools.web_search 决定
+func init() {
+	tol.RegisterBuiltin("web_search", NewSearchTool, func() any { return &Config{} })
+}
 
 // Config 搜索工具配置
 type Config struct {
-	CacheDuration string `json:"cache_duration"` // 缓存时长（如"5m"）
+	CacheDuration string `json:"cache_duration" jsonschema:"format=duration"` // 搜索结果缓存时长（如"5m"），为空时使用 middleware.cache.ttl
+	Timeout       string `json:"timeout" jsonschema:"format=duration"`        // 单次搜索请求的HTTP超时（默认30s）
+	Proxy         string `json:"proxy"`                                       // 搜索请求及健康检查使用的代理（如"http://127.0.0.1:10808"，支持http/https/socks5），为空时直连
 }
 
+// defaultTimeout 搜索请求默认超时
+const defaultTimeout = 30 * time.Second
+
 // NewSearchTool 构造函数（实现ToolConstructor）
 func NewSearchTool(ctx context.Context, cfg any, deps tol.Dependencies) (tol.Tool, error) {
 	searchCfg, ok := cfg.(*Config)
@@ -34,6 +45,15 @@ func NewSearchTool(ctx context.Context, cfg any, deps tol.Dependencies) (tol.Too
 		return nil, fmt.Errorf("invalid config type for search tool")
 	}
 
+	probe := &http.Client{}
+	if searchCfg.Proxy != "" {
+		proxy, err := url.Parse(searchCfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parse search proxy: %w", err)
+		}
+		probe.Transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
+	}
+
 	// 实际应使用具体搜索工具的配置
 	impl, err := duckduckgo.NewTool(ctx, &duckduckgo.Config{
 		ToolName:   "web_search",
@@ -41,29 +61,31 @@ func NewSearchTool(ctx context.Context, cfg any, deps tol.Dependencies) (tol.Too
 		MaxResults: 5,                  // 默认结果数量
 		Region:     ddgsearch.RegionCN, // 使用中国区域
 		DDGConfig: &ddgsearch.Config{
-			Timeout:    parseDuration(searchCfg.CacheDuration),
-			MaxRetries: 5,                        // 重试次数
-			Proxy:      "http://127.0.0.1:10808", // 代理地址
+			Timeout:    parseDuration(searchCfg.Timeout, defaultTimeout),
+			MaxRetries: 5,               // 重试次数
+			Proxy:      searchCfg.Proxy, // 代理地址，为空时直连
 		},
 	})
 	if err != nil {
-		logger.Error("create search tool failed: %w", zap.Error(err))
+		log.GetLogger().Error("create search tool failed", zap.Error(err))
 		return nil, fmt.Errorf("create search tool failed: %w", err)
 	}
 
 	return &SearchTool{
-		impl: impl,
-		cfg:  searchCfg,
+		impl:  impl,
+		cfg:   searchCfg,
+		probe: probe,
 	}, nil
 }
 
-func parseDuration(duration string) time.Duration {
+// parseDuration 解析时长，为空或解析失败时返回默认值
+func parseDuration(duration string, def time.Duration) time.Duration {
 	if duration == "" {
-		return time.Minute // 默认缓存1分钟
+		return def
 	}
 	dur, err := time.ParseDuration(duration)
 	if err != nil {
-		return time.Minute // 解析失败，返回默认值
+		return def
 	}
 	return dur
 }
@@ -73,6 +95,10 @@ func (t *SearchTool) GetDescriptor() *mcp.Tool {
 	tol := mcp.NewTool("web_search",
 		mcp.WithDescription("网页搜索工具（获取实时信息）"),
 		mcp.WithString("query", mcp.Required(), mcp.Description("搜索关键词")),
+		// 只读且幂等，结果可由缓存中间件缓存
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithDestructiveHintAnnotation(false),
+		mcp.WithIdempotentHintAnnotation(true),
 	)
 	return &tol
 }
@@ -85,18 +111,18 @@ func (t *SearchTool) Execute(ctx context.Context, req mcp.CallToolRequest) (*mcp
 		return mcp.NewToolResultError("参数格式错误: 需要是map类型"), nil
 	}
 
-	//query, ok := args["query"].(string)
-	//if !ok {
-	//	return mcp.NewToolResultError("缺少或无效的query参数"), nil
-	//}
+	query, ok := args["query"].(string)
+	if !ok || query == "" {
+		return mcp.NewToolResultError("缺少或无效的query参数"), nil
+	}
 
 	searchReq := &duckduckgo.SearchRequest{
-		Query: "Golang programming development",
+		Query: query,
 		Page:  1,
 	}
 	jsonReq, err := json.Marshal(searchReq)
 	if err != nil {
-		logger.Error("参数序列化失败", zap.Error(err))
+		log.GetLogger().Error("参数序列化失败", zap.Error(err))
 	}
 	// 调用 InvokableTool 接口
 	result, err := t.impl.InvokableRun(ctx, string(jsonReq))
@@ -107,6 +133,32 @@ func (t *SearchTool) Execute(ctx context.Context, req mcp.CallToolRequest) (*mcp
 	return mcp.NewToolResultText(result), nil
 }
 
+// CacheTTL 实现tool.CacheTTLer接口，由 cache_duration 决定结果缓存时长
+func (t *SearchTool) CacheTTL() time.Duration {
+	return parseDuration(t.cfg.CacheDuration, 0)
+}
+
+// healthProbeURL 健康检查探测的地址
+const healthProbeURL = "https://duckduckgo.com/"
+
+// HealthCheck 实现tool.HealthChecker接口，经配置的代理（未配置时直连）向搜索服务发送HEAD请求确认网络连通，不发起实际搜索（避免消耗配额或触发限流）
+// 服务返回5xx或请求失败（代理不可用、DNS、超时等）视为不可用
+func (t *SearchTool) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, healthProbeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.probe.Do(req)
+	if err != nil {
+		return fmt.Errorf("search probe failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("search probe failed: %s", resp.Status)
+	}
+	return nil
+}
+
 // Name 实现工具接口
 func (t *SearchTool) Name() string {
 	return "web_search"
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"mcp-server/internal/log"
+	"mcp-server/internal/tool"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthCheckUsesConfiguredProxy(t *testing.T) {
+	// 代理拒绝所有请求，只记录经过的目标地址
+	targets := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case targets <- r.Host:
+		default:
+		}
+		http.Error(w, "proxy denied", http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	searchTool, err := NewSearchTool(context.Background(), &Config{Proxy: proxy.URL}, tool.Dependencies{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := searchTool.(tool.HealthChecker).HealthCheck(context.Background()); err == nil {
+		t.Error("HealthCheck() succeeded through a rejecting proxy")
+	}
+	select {
+	case target := <-targets:
+		if target != "duckduckgo.com:443" {
+			t.Errorf("proxy target = %q, want duckduckgo.com:443", target)
+		}
+	default:
+		t.Error("health probe did not go through the configured proxy")
+	}
+}
+
+func TestNewSearchToolProxy(t *testing.T) {
+	log.Logger = zap.NewNop()
+	tests := []struct {
+		name      string
+		proxy     string
+		wantProxy bool
+		wantErr   bool
+	}{
+		{name: "未配置代理时直连"},
+		{name: "配置代理", proxy: "socks5://127.0.0.1:1080", wantProxy: true},
+		{name: "不支持的代理协议", proxy: "ftp://127.0.0.1:21", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSearchTool(context.Background(), &Config{Proxy: tt.proxy}, tool.Dependencies{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSearchTool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if hasProxy := got.(*SearchTool).probe.Transport != nil; hasProxy != tt.wantProxy {
+				t.Errorf("probe uses proxy = %v, want %v", hasProxy, tt.wantProxy)
+			}
+		})
+	}
+}
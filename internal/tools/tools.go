@@ -0,0 +1,8 @@
+// Package tools 汇总所有内置工具，导入本包即可让各工具在init中注册到内置工具注册表
+package tools
+
+import (
+	_ "mcp-server/internal/tools/browseruse"
+	_ "mcp-server/internal/tools/milvus"
+	_ "mcp-server/internal/tools/search"
+)
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+	"mcp-server/config"
+	"mcp-server/coordinator"
+	"mcp-server/internal/log"
+	"mcp-server/internal/tool"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// app 各命令共用的运行时组件（配置、聊天模型与工具）
+type app struct {
+	cfg         *config.AppConfig
+	chatModel   *openai.ChatModel
+	toolManager *tool.ToolManager
+	limiter     *tool.Limiter
+	sessions    *liveSessions // 已连接的MCP会话，/messages只接受这些会话的ID
+}
+
+// newApp 加载配置、创建聊天模型并初始化所有启用的工具（初始化失败的工具降级，不影响其他工具）
+func newApp(ctx context.Context, configPath string, logger *zap.Logger) (*app, error) {
+	// 1. 加载配置
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置失败: %w", err)
+	}
+	logger.Info("配置加载成功", zap.String("path", configPath))
+
+	// 2. 初始化公共依赖（如OpenAI模型）
+	if cfg.ChatModel.APIKey == "" {
+		logger.Warn("未配置聊天模型API Key（chat_model.api_key 或 MCP_CHAT_MODEL_API_KEY）")
+	}
+	chatModel, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		APIKey:  cfg.ChatModel.APIKey,
+		BaseURL: cfg.ChatModel.BaseURL,
+		Model:   cfg.ChatModel.Model,
+	})
+	if err != nil {
+		logger.Error("创建聊天模型失败", zap.Error(err))
+	}
+
+	// 3. 初始化工具管理器，注册所有内置工具，是否启用由配置 tools.<name>.enabled 决定
+	toolManager := tool.NewToolManager(tool.Dependencies{
+		ChatModel: chatModel,
+	})
+	if err := toolManager.RegisterBuiltins(); err != nil {
+		return nil, fmt.Errorf("注册内置工具失败: %w", err)
+	}
+	limiter, err := tool.NewLimiter(cfg.Middleware)
+	if err != nil {
+		return nil, err
+	}
+	middlewares, err := cfg.Middleware.Middlewares(logger, toolManager, limiter)
+	if err != nil {
+		return nil, err
+	}
+	toolManager.Use(middlewares...)
+
+	// 4. 初始化所有启用的工具
+	toolManager.SetRequired(cfg.RequiredTools)
+	if err := toolManager.SetAliases(cfg.ToolAliases); err != nil {
+		return nil, err
+	}
+	if err := toolManager.InitTools(ctx, cfg.Tools); err != nil {
+		logger.Warn("部分工具初始化失败，以降级模式运行", zap.Error(err))
+	}
+	for _, status := range toolManager.Status() {
+		logger.Info("工具状态", zap.String("tool", status.Name), zap.String("status", status.Status))
+	}
+
+	return &app{
+		cfg:         cfg,
+		chatModel:   chatModel,
+		toolManager: toolManager,
+		limiter:     limiter,
+		sessions:    newLiveSessions(),
+	}, nil
+}
+
+// newCoordinator 按配置创建协调器
+func (a *app) newCoordinator() (*coordinator.Coordinator, error) {
+	sessionStore, err := coordinator.NewSessionStore(a.cfg.Coordinator.Session)
+	if err != nil {
+		return nil, fmt.Errorf("创建会话存储失败: %w", err)
+	}
+	return coordinator.NewCoordinator(a.chatModel, a.toolManager, &a.cfg.Coordinator,
+		coordinator.WithSessionStore(sessionStore), coordinator.WithLogger(log.GetLogger())), nil
+}
+
+// runServe serve命令：启动MCP服务
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := configFlag(fs)
+	transport := fs.String("transport", transportSSE+","+transportStreamableHTTP,
+		"传输方式（sse / streamable-http / stdio），HTTP传输可用逗号同时启用多个")
+	addr := fs.String("addr", "", "监听地址，如 localhost:8080，端口为0时随机分配（默认为配置 http.addr 或 localhost:<server_port>）")
+	mcpPrefix := fs.String("mcp-prefix", "", "MCP端点在 http.base_path 下的路径前缀，如 /v1 时端点为 /v1/sse、/v1/message、/v1/mcp")
+	stateless := fs.Bool("stateless", false, "streamable HTTP不签发会话ID（无状态模式）")
+	logFile := fs.String("log-file", "", "日志输出文件（默认stderr）")
+	fs.Parse(args)
+
+	transports, err := parseTransports(*transport)
+	if err != nil {
+		return err
+	}
+	if *logFile != "" {
+		if err := log.InitWithOutput(*logFile); err != nil {
+			return fmt.Errorf("初始化日志失败: %w", err)
+		}
+	}
+
+	logger := log.GetLogger()
+	ctx := context.Background()
+	a, err := newApp(ctx, *configPath, logger)
+	if err != nil {
+		return err
+	}
+	shutdownTimeout, err := time.ParseDuration(a.cfg.HTTP.ShutdownTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid http.shutdown_timeout %q: %w", a.cfg.HTTP.ShutdownTimeout, err)
+	}
+
+	// 收到SIGINT/SIGTERM后停止服务，工具仍使用ctx，关闭前不会被取消
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 创建MCP服务器
+	svr := server.NewMCPServer("multi-tool-service", "1.0", server.WithToolCapabilities(true), server.WithHooks(a.sessions.hooks()))
+	a.toolManager.RegisterToServer(svr)
+
+	// 监听配置文件变化（或SIGHUP），只重建配置有变化的工具并通知客户端工具列表变化
+	watcher := config.NewWatcher(*configPath, 0, func(newCfg *config.AppConfig, err error) {
+		if err != nil {
+			logger.Error("重新加载配置失败", zap.Error(err))
+			return
+		}
+		a.toolManager.SetRequired(newCfg.RequiredTools)
+		if err := a.toolManager.SetAliases(newCfg.ToolAliases); err != nil {
+			logger.Error("更新工具别名失败，保留原有别名", zap.Error(err))
+		}
+		changed, err := a.toolManager.Reload(ctx, newCfg.Tools)
+		if err != nil {
+			logger.Error("热加载工具失败", zap.Error(err))
+		}
+		logger.Info("配置已重新加载", zap.Strings("changed_tools", changed))
+	})
+	go watcher.Run(sigCtx)
+
+	if transports[transportStdio] {
+		err := serveStdio(sigCtx, svr, logger)
+		drainCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+		defer cancel()
+		a.drainTools(drainCtx, logger)
+		a.closeTools(logger)
+		logger.Info("MCP服务已退出")
+		return err
+	}
+
+	coor, err := a.newCoordinator()
+	if err != nil {
+		return err
+	}
+	// 周期性检查工具健康状态，结果反映在 /health 与 /readyz 中
+	go a.toolManager.MonitorHealth(sigCtx, healthCheckInterval, healthCheckTimeout)
+	return serveHTTP(sigCtx, a, svr, coor, httpOptions{
+		addr:            *addr,
+		transports:      transports,
+		mcpPrefix:       *mcpPrefix,
+		stateless:       *stateless,
+		shutdownTimeout: shutdownTimeout,
+	}, logger)
+}
+
+// httpOptions 命令行指定的HTTP服务选项
+type httpOptions struct {
+	addr            string          // 监听地址，为空时使用配置
+	transports      map[string]bool // 启用的HTTP传输
+	mcpPrefix       string          // MCP端点相对于base_path的前缀
+	stateless       bool            // streamable HTTP无状态模式
+	shutdownTimeout time.Duration   // 退出时等待进行中请求的最长时间
+}
+
+// serveHTTP 在一个HTTP服务上提供MCP传输端点及自定义接口，监听成功后才报告启动，ctx结束（收到退出信号）时优雅关闭
+func serveHTTP(ctx context.Context, a *app, svr *server.MCPServer, coor *coordinator.Coordinator, opts httpOptions, logger *zap.Logger) error {
+	httpCfg := a.cfg.HTTP
+	addr := opts.addr
+	if addr == "" {
+		addr = httpCfg.Addr
+	}
+	if addr == "" {
+		addr = fmt.Sprintf("localhost:%s", a.cfg.ServerPort)
+	}
+
+	srv, err := newHTTPServer(httpCfg, logger)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("监听 %s 失败: %w", addr, err)
+	}
+	bound := ln.Addr().String()
+
+	basePath := path.Join("/", httpCfg.BasePath)
+	tracker := newRequestTracker()
+	// 所有请求上下文派生自requestCtx，关闭超时后统一取消（协调器的模型与工具调用随之结束）
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv.BaseContext = func(net.Listener) context.Context { return requestCtx }
+	srv.Handler = newMux(a, svr, coor, opts, basePath, strings.TrimSuffix(httpCfg.PublicURL, "/"), tracker, logger)
+	logger.Info("MCP服务已启动", zap.String("addr", bound), zap.String("base_path", basePath))
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			a.closeTools(logger)
+			return fmt.Errorf("HTTP服务异常退出: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	logger.Info("收到退出信号，开始关闭服务", zap.Duration("timeout", opts.shutdownTimeout))
+	shutdownHTTP(a, srv, tracker, cancelRequests, opts.shutdownTimeout, logger)
+	logger.Info("MCP服务已退出")
+	return nil
+}
+
+// newHTTPServer 按配置创建HTTP服务（超时与请求头大小）
+func newHTTPServer(cfg config.HTTPConfig, logger *zap.Logger) (*http.Server, error) {
+	srv := &http.Server{
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+		ErrorLog:       zap.NewStdLog(logger),
+	}
+	timeouts := []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"read_timeout", cfg.ReadTimeout, &srv.ReadTimeout},
+		{"read_header_timeout", cfg.ReadHeaderTimeout, &srv.ReadHeaderTimeout},
+		{"write_timeout", cfg.WriteTimeout, &srv.WriteTimeout},
+		{"idle_timeout", cfg.IdleTimeout, &srv.IdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(t.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http.%s %q: %w", t.name, t.value, err)
+		}
+		*t.target = d
+	}
+	return srv, nil
+}
+
+// newMux 组装所有路由，路径均位于basePath之下；publicURL为空时SSE的消息端点以相对路径告知客户端
+func newMux(a *app, svr *server.MCPServer, coor *coordinator.Coordinator, opts httpOptions, basePath, publicURL string, tracker *requestTracker, logger *zap.Logger) *http.ServeMux {
+	mux := http.NewServeMux()
+	mcpBase := path.Join(basePath, opts.mcpPrefix)
+	// /messages携带的sessionId须是已连接的会话，或有状态streamable HTTP签发且仍有效的会话ID
+	sessionKnown := a.sessions.has
+
+	// 旧版SSE传输：/sse建立事件流，/message接收客户端消息
+	if opts.transports[transportSSE] {
+		sseServer := server.NewSSEServer(svr,
+			server.WithBaseURL(publicURL),
+			server.WithUseFullURLForMessageEndpoint(publicURL != ""),
+			server.WithStaticBasePath(mcpBase))
+		mux.Handle(sseServer.CompleteSsePath(), tracker.mcp(sseServer.SSEHandler()))
+		mux.Handle(sseServer.CompleteMessagePath(), tracker.mcp(sseServer.MessageHandler()))
+		logger.Info("已启用SSE传输", zap.String("path", sseServer.CompleteSsePath()))
+	}
+
+	// streamable HTTP传输：单个端点，支持会话ID与监听流断线续传
+	if opts.transports[transportStreamableHTTP] {
+		mcpPath := path.Join(mcpBase, "mcp")
+		streamOpts := []server.StreamableHTTPOption{
+			server.WithEndpointPath(mcpPath),
+			server.WithHeartbeatInterval(mcpHeartbeatInterval),
+		}
+		var ids *sessionIDManager
+		if opts.stateless {
+			streamOpts = append(streamOpts, server.WithStateLess(true))
+		} else {
+			ids = newSessionIDManager(defaultMCPSessionTTL)
+			streamOpts = append(streamOpts, server.WithSessionIdManager(ids))
+			sessionKnown = func(sessionID string) bool {
+				return a.sessions.has(sessionID) || ids.valid(sessionID)
+			}
+		}
+		mux.Handle(mcpPath, tracker.mcp(newResumableHandler(server.NewStreamableHTTPServer(svr, streamOpts...), defaultMCPSessionTTL, ids)))
+		logger.Info("已启用streamable HTTP传输", zap.String("path", mcpPath))
+	}
+
+	// 协调器接口
+	mux.Handle(path.Join(basePath, "messages"), tracker.track(newMessagesHandler(coor, svr, sessionKnown, logger)))
+
+	// 健康检查（包含各工具状态）
+	mux.Handle(path.Join(basePath, "health"), newHealthHandler(a.toolManager))
+
+	// Kubernetes探针：存活只反映进程状态，就绪反映各工具的初始化与健康检查结果
+	mux.Handle(path.Join(basePath, "healthz"), newLivenessHandler())
+	mux.Handle(path.Join(basePath, "readyz"), newReadinessHandler(a.toolManager))
+
+	// Prometheus格式的指标（工具状态与限流状态）
+	mux.Handle(path.Join(basePath, "metrics"), newMetricsHandler(a.toolManager, a.limiter))
+	return mux
+}
+
+// serveStdio 通过stdin/stdout提供MCP服务，直到stdin关闭或ctx结束（收到退出信号）
+// stdout只用于JSON-RPC消息：日志写到stderr或文件，其他代码（包括第三方库）误写到stdout的内容也会转到stderr
+func serveStdio(ctx context.Context, svr *server.MCPServer, logger *zap.Logger) error {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	stdioServer := server.NewStdioServer(svr)
+	stdioServer.SetErrorLogger(zap.NewStdLog(logger))
+	logger.Info("MCP服务启动", zap.String("transport", transportStdio))
+	if err := stdioServer.Listen(ctx, os.Stdin, stdout); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("stdio服务异常退出: %w", err)
+	}
+	return nil
+}
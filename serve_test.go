@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+	"mcp-server/internal/tool"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readEndpointEvent 连接SSE端点并返回endpoint事件的数据
+func readEndpointEvent(t *testing.T, url string) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s = %d", url, resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	endpoint := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "event: endpoint" {
+			endpoint = true
+			continue
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok && endpoint {
+			return data
+		}
+	}
+	t.Fatal("no endpoint event")
+	return ""
+}
+
+func TestSSEEndpointUnderBasePath(t *testing.T) {
+	limiter, err := tool.NewLimiter(tool.MiddlewareConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &app{toolManager: tool.NewToolManager(tool.Dependencies{}), limiter: limiter, sessions: newLiveSessions()}
+	opts := httpOptions{transports: map[string]bool{transportSSE: true}, mcpPrefix: "/v1"}
+
+	tests := []struct {
+		name      string
+		publicURL string
+		want      string
+	}{
+		{name: "未配置外部地址时使用相对路径", want: "/api/v1/message?sessionId="},
+		{name: "配置外部地址时使用完整URL", publicURL: "https://mcp.example.com", want: "https://mcp.example.com/api/v1/message?sessionId="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := server.NewMCPServer("test", "1.0.0")
+			mux := newMux(a, svr, nil, opts, "/api", tt.publicURL, newRequestTracker(), zap.NewNop())
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+
+			if got := readEndpointEvent(t, ts.URL+"/api/v1/sse"); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("endpoint = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	toolCloseTimeout  = 10 * time.Second       // 关闭工具（浏览器进程、数据库连接）的超时
+	drainPollInterval = 100 * time.Millisecond // 等待进行中请求完成时的轮询间隔
+	cancelGracePeriod = 2 * time.Second        // 取消超时未完成的请求后等待其返回的时间
+)
+
+// requestTracker 跟踪进行中的HTTP请求以便优雅关闭：
+// 普通请求（工具调用、协调器运行）等待其完成；事件流（SSE /sse 与 streamable HTTP 的GET监听流）不会自行结束，关闭时主动断开
+type requestTracker struct {
+	active  atomic.Int64
+	streams chan struct{} // 关闭后断开所有事件流
+	once    sync.Once
+}
+
+// newRequestTracker 创建请求跟踪器
+func newRequestTracker() *requestTracker {
+	return &requestTracker{streams: make(chan struct{})}
+}
+
+// track 包装普通请求的处理器，记录进行中的请求数
+func (t *requestTracker) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.active.Add(1)
+		defer t.active.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// mcp 包装MCP传输端点：GET为事件流，关闭时取消其请求上下文；其他方法按普通请求跟踪
+func (t *requestTracker) mcp(next http.Handler) http.Handler {
+	tracked := t.track(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			tracked.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-t.streams:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// wait 等待所有普通请求完成，直到ctx结束
+func (t *requestTracker) wait(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for t.active.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for %d in-flight requests: %w", t.active.Load(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// closeStreams 断开所有事件流
+func (t *requestTracker) closeStreams() {
+	t.once.Do(func() { close(t.streams) })
+}
+
+// shutdownHTTP 优雅关闭HTTP服务：停止接受新连接，在timeout内等待进行中的请求与工具调用完成，
+// 再断开事件流（SSE传输的工具结果经事件流返回，因此最后断开），最后关闭所有工具
+// 超时仍未完成的请求通过cancelRequests（所有请求上下文的父上下文）取消，等其返回后才关闭工具，避免工具在调用中被关闭
+func shutdownHTTP(a *app, srv *http.Server, tracker *requestTracker, cancelRequests context.CancelFunc, timeout time.Duration, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	defer cancelRequests()
+
+	// Shutdown会先关闭监听，再等待所有连接结束（事件流断开后才会返回）
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+
+	if err := tracker.wait(ctx); err != nil {
+		logger.Warn("等待进行中的请求超时，取消剩余请求", zap.Error(err))
+		cancelRequests()
+		graceCtx, graceCancel := context.WithTimeout(context.Background(), cancelGracePeriod)
+		if err := tracker.wait(graceCtx); err != nil {
+			logger.Warn("取消后仍有请求未结束", zap.Error(err))
+		}
+		graceCancel()
+	}
+	a.drainTools(ctx, logger)
+	tracker.closeStreams()
+	if err := <-shutdownErr; err != nil {
+		logger.Warn("关闭HTTP服务超时，强制断开剩余连接", zap.Error(err))
+		srv.Close()
+	}
+	a.closeTools(logger)
+}
+
+// drainTools 停止接受新的工具调用并等待进行中的调用完成
+func (a *app) drainTools(ctx context.Context, logger *zap.Logger) {
+	if err := a.toolManager.Drain(ctx); err != nil {
+		logger.Warn("等待进行中的工具调用超时", zap.Error(err))
+	}
+}
+
+// closeTools 关闭所有工具，释放浏览器进程、数据库连接等资源
+func (a *app) closeTools(logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), toolCloseTimeout)
+	defer cancel()
+	if err := a.toolManager.Close(ctx); err != nil {
+		logger.Error("关闭工具失败", zap.Error(err))
+	}
+}
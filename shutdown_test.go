@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"mcp-server/internal/tool"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownCancelsRequestsAfterTimeout(t *testing.T) {
+	a := &app{toolManager: tool.NewToolManager(tool.Dependencies{})}
+	tracker := newRequestTracker()
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	handler := tracker.track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	srv := &http.Server{Handler: handler, BaseContext: func(net.Listener) context.Context { return requestCtx }}
+	go srv.Serve(ln)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		shutdownHTTP(a, srv, tracker, cancelRequests, 100*time.Millisecond, zap.NewNop())
+		close(done)
+	}()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight request was not cancelled after the shutdown timeout")
+	}
+	select {
+	case <-done:
+	case <-time.After(cancelGracePeriod + time.Second):
+		t.Fatal("shutdownHTTP did not return")
+	}
+	if n := tracker.active.Load(); n != 0 {
+		t.Errorf("active requests after shutdown = %d, want 0", n)
+	}
+}
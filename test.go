@@ -9,6 +9,7 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"log"
+	"mcp-server/config"
 )
 
 func main1() {
@@ -19,17 +20,21 @@ func main1() {
 func startMCPServer() {
 	svr := server.NewMCPServer("browser-service", mcp.LATEST_PROTOCOL_VERSION)
 
+	cfg, err := config.LoadConfig("config.json")
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
 	chatModel, err := openai.NewChatModel(context.Background(), &openai.ChatModelConfig{
-		APIKey:  "sk-14d8e0db18214302a2ae13e493094d98",
-		BaseURL: "https://dashscope.aliyuncs.com/compatible-mode/v1",
-		Model:   "qwen-plus",
+		APIKey:  cfg.ChatModel.APIKey,
+		BaseURL: cfg.ChatModel.BaseURL,
+		Model:   cfg.ChatModel.Model,
 	})
 	if err != nil {
 		log.Fatalf("创建聊天模型失败: %v", err)
 	}
 
 	//bingTool, err := bingsearch.NewTool(context.Background(), &bingsearch.Config{
-	//	APIKey: "sk-14d8e0db18214302a2ae13e493094d98",
+	//	APIKey: os.Getenv("BING_API_KEY"),
 	//	Cache:  5 * time.Minute,
 	//})
 
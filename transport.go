@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/mark3labs/mcp-go/server"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 传输方式
+const (
+	transportSSE            = "sse"             // 旧版 HTTP + SSE（/sse + /message）
+	transportStreamableHTTP = "streamable-http" // 新版单端点 streamable HTTP（/mcp）
+	transportStdio          = "stdio"           // 标准输入输出（由桌面客户端以子进程方式启动）
+)
+
+const (
+	defaultMCPSessionTTL   = time.Hour        // streamable HTTP会话空闲过期时间
+	mcpHeartbeatInterval   = 30 * time.Second // 监听流的心跳间隔，避免代理断开空闲连接
+	maxBufferedEvents      = 100              // 每个会话保留的最近事件数（用于断线续传）
+	headerMCPSessionID     = "Mcp-Session-Id"
+	headerLastEventID      = "Last-Event-ID"
+	mcpSessionIDPrefix     = "mcp-"
+	sseEventPrefix         = "event:"
+	sseDataPrefix          = "data:"
+	sessionEvictionEntries = 1024 // 会话数超过该值时才清理过期会话
+)
+
+// parseTransports 解析逗号分隔的传输方式，stdio不能与HTTP传输同时使用
+func parseTransports(value string) (map[string]bool, error) {
+	transports := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case transportSSE, transportStreamableHTTP, transportStdio:
+			transports[name] = true
+		case "":
+		default:
+			return nil, fmt.Errorf("不支持的传输方式: %s", name)
+		}
+	}
+	if len(transports) == 0 {
+		return nil, fmt.Errorf("至少需要一种传输方式")
+	}
+	if transports[transportStdio] && len(transports) > 1 {
+		return nil, fmt.Errorf("stdio传输不能与其他传输方式同时使用")
+	}
+	return transports, nil
+}
+
+// sessionIDManager streamable HTTP的有状态会话ID管理：只接受本服务签发且未终止、未过期的会话ID
+type sessionIDManager struct {
+	ttl        time.Duration
+	active     map[string]time.Time // 会话ID -> 最近活跃时间
+	terminated map[string]time.Time // 已终止的会话ID -> 终止时间（过期后清理）
+	mu         sync.Mutex
+}
+
+// newSessionIDManager 创建会话ID管理器
+func newSessionIDManager(ttl time.Duration) *sessionIDManager {
+	return &sessionIDManager{
+		ttl:        ttl,
+		active:     make(map[string]time.Time),
+		terminated: make(map[string]time.Time),
+	}
+}
+
+// Generate 实现server.SessionIdManager接口，签发随机会话ID
+func (m *sessionIDManager) Generate() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := mcpSessionIDPrefix + hex.EncodeToString(b)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.evictExpired(now)
+	m.active[id] = now
+	return id
+}
+
+// Validate 实现server.SessionIdManager接口，已终止、已过期或未知（如服务重启前签发）的会话返回isTerminated，
+// 客户端收到404后按规范重新初始化会话
+func (m *sessionIDManager) Validate(sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.terminated[sessionID]; ok {
+		return true, nil
+	}
+	lastSeen, ok := m.active[sessionID]
+	if !ok {
+		return true, nil
+	}
+	now := time.Now()
+	if now.Sub(lastSeen) > m.ttl {
+		delete(m.active, sessionID)
+		m.terminated[sessionID] = now
+		return true, nil
+	}
+	m.active[sessionID] = now
+	return false, nil
+}
+
+// Terminate 实现server.SessionIdManager接口，客户端通过DELETE主动结束会话；会话未知时视为已结束
+func (m *sessionIDManager) Terminate(sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.active[sessionID]; !ok {
+		return false, nil
+	}
+	delete(m.active, sessionID)
+	m.terminated[sessionID] = time.Now()
+	return false, nil
+}
+
+// valid 判断会话ID是否由本服务签发且仍有效
+func (m *sessionIDManager) valid(sessionID string) bool {
+	terminated, _ := m.Validate(sessionID)
+	return !terminated
+}
+
+// evictExpired 会话较多时清理过期的活跃会话及终止记录（调用方需持有锁）
+func (m *sessionIDManager) evictExpired(now time.Time) {
+	if len(m.active)+len(m.terminated) < sessionEvictionEntries {
+		return
+	}
+	for id, lastSeen := range m.active {
+		if now.Sub(lastSeen) > m.ttl {
+			delete(m.active, id)
+		}
+	}
+	for id, at := range m.terminated {
+		if now.Sub(at) > m.ttl {
+			delete(m.terminated, id)
+		}
+	}
+}
+
+// bufferedEvent 已发送的SSE事件
+type bufferedEvent struct {
+	id   int64
+	data []byte
+}
+
+// sessionEvents 单个会话最近发送的事件
+type sessionEvents struct {
+	nextID     int64
+	events     []bufferedEvent
+	lastActive time.Time
+}
+
+// liveSessions 记录已在MCP服务器注册的会话（SSE会话与streamable HTTP监听流），随连接断开移除
+type liveSessions struct {
+	ids sync.Map
+}
+
+// newLiveSessions 创建会话记录
+func newLiveSessions() *liveSessions {
+	return &liveSessions{}
+}
+
+// hooks 返回注册到MCP服务器的会话钩子
+func (s *liveSessions) hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.ids.Store(session.SessionID(), struct{}{})
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		s.ids.Delete(session.SessionID())
+	})
+	return hooks
+}
+
+// has 判断会话是否仍处于连接状态
+func (s *liveSessions) has(sessionID string) bool {
+	_, ok := s.ids.Load(sessionID)
+	return ok
+}
+
+// resumableHandler 为streamable HTTP的监听流（GET）增加断线续传：
+// 给每个SSE事件加上id并按会话缓存最近的事件（心跳ping不缓存），客户端带 Last-Event-ID 重连时先补发之后的事件
+// 注意：只能补发断线前已写出但客户端未收到的事件，断线期间产生的通知无法保留
+type resumableHandler struct {
+	next     http.Handler
+	ttl      time.Duration
+	ids      *sessionIDManager // 有状态模式下校验GET/DELETE携带的会话ID（mcp-go只校验POST），无状态模式为nil
+	sessions map[string]*sessionEvents
+	mu       sync.Mutex
+}
+
+// newResumableHandler 包装streamable HTTP处理器，ids为空时不校验会话ID
+func newResumableHandler(next http.Handler, ttl time.Duration, ids *sessionIDManager) *resumableHandler {
+	return &resumableHandler{
+		next:     next,
+		ttl:      ttl,
+		ids:      ids,
+		sessions: make(map[string]*sessionEvents),
+	}
+}
+
+// ServeHTTP 实现http.Handler接口
+// GET/DELETE携带未知、已终止或已过期的会话ID时返回404，不建立监听流，也不补发该ID下缓存的事件
+func (h *resumableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Header.Get(headerMCPSessionID)
+	if h.ids != nil && sessionID != "" && (r.Method == http.MethodGet || r.Method == http.MethodDelete) {
+		if terminated, _ := h.ids.Validate(sessionID); terminated {
+			h.forget(sessionID)
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodDelete && sessionID != "":
+		h.forget(sessionID)
+	case r.Method == http.MethodGet && sessionID != "":
+		rec := &eventRecorder{ResponseWriter: w, handler: h, sessionID: sessionID}
+		if lastID, err := strconv.ParseInt(r.Header.Get(headerLastEventID), 10, 64); err == nil {
+			rec.replay = h.since(sessionID, lastID)
+		}
+		w = rec
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// forget 丢弃会话缓存的事件
+func (h *resumableHandler) forget(sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, sessionID)
+}
+
+// since 返回会话中id大于lastID的事件，会话已过期时丢弃其缓存
+func (h *resumableHandler) since(sessionID string, lastID int64) []bufferedEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sess, ok := h.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	if time.Since(sess.lastActive) > h.ttl {
+		delete(h.sessions, sessionID)
+		return nil
+	}
+	var missed []bufferedEvent
+	for _, event := range sess.events {
+		if event.id > lastID {
+			missed = append(missed, event)
+		}
+	}
+	return missed
+}
+
+// record 为事件分配id并缓存
+func (h *resumableHandler) record(sessionID string, data []byte) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	sess, ok := h.sessions[sessionID]
+	if !ok {
+		h.evictExpired(now)
+		sess = &sessionEvents{}
+		h.sessions[sessionID] = sess
+	}
+	sess.nextID++
+	sess.events = append(sess.events, bufferedEvent{id: sess.nextID, data: append([]byte(nil), data...)})
+	if len(sess.events) > maxBufferedEvents {
+		sess.events = sess.events[len(sess.events)-maxBufferedEvents:]
+	}
+	sess.lastActive = now
+	return sess.nextID
+}
+
+// evictExpired 缓存的会话较多时清理过期会话（只在新建会话缓存时检查，调用方需持有锁）
+func (h *resumableHandler) evictExpired(now time.Time) {
+	if len(h.sessions) < sessionEvictionEntries {
+		return
+	}
+	for id, sess := range h.sessions {
+		if now.Sub(sess.lastActive) > h.ttl {
+			delete(h.sessions, id)
+		}
+	}
+}
+
+// isPing 判断SSE事件是否为心跳ping请求（不需要补发，也不应挤占事件缓存）
+func isPing(event []byte) bool {
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		data, ok := bytes.CutPrefix(line, []byte(sseDataPrefix))
+		if !ok {
+			continue
+		}
+		var msg struct {
+			ID     any    `json:"id"`
+			Method string `json:"method"`
+		}
+		return json.Unmarshal(bytes.TrimSpace(data), &msg) == nil && msg.Method == "ping" && msg.ID != nil
+	}
+	return false
+}
+
+// eventRecorder 监听流的ResponseWriter包装：为事件加上id，并在响应头发送后补发客户端错过的事件
+type eventRecorder struct {
+	http.ResponseWriter
+	handler   *resumableHandler
+	sessionID string
+	replay    []bufferedEvent
+}
+
+// Write 为完整的SSE事件加上id行后写出，心跳ping原样写出
+func (w *eventRecorder) Write(p []byte) (int, error) {
+	if !bytes.HasPrefix(p, []byte(sseEventPrefix)) {
+		return w.ResponseWriter.Write(p)
+	}
+	if err := w.writeReplay(); err != nil {
+		return 0, err
+	}
+	if isPing(p) {
+		return w.ResponseWriter.Write(p)
+	}
+	id := w.handler.record(w.sessionID, p)
+	if _, err := fmt.Fprintf(w.ResponseWriter, "id: %d\n", id); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush 实现http.Flusher接口，首次刷新（响应头已发送）时补发错过的事件
+func (w *eventRecorder) Flush() {
+	w.writeReplay()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeReplay 补发错过的事件（只执行一次）
+func (w *eventRecorder) writeReplay() error {
+	replay := w.replay
+	w.replay = nil
+	for _, event := range replay {
+		if _, err := fmt.Fprintf(w.ResponseWriter, "id: %d\n%s", event.id, event.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
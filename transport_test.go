@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/mark3labs/mcp-go/server"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionIDManager(t *testing.T) {
+	m := newSessionIDManager(time.Hour)
+	id := m.Generate()
+	if !strings.HasPrefix(id, mcpSessionIDPrefix) {
+		t.Fatalf("Generate() = %q, want prefix %q", id, mcpSessionIDPrefix)
+	}
+
+	if terminated, err := m.Validate(id); terminated || err != nil {
+		t.Errorf("Validate(active) = %v, %v", terminated, err)
+	}
+	// 未知的会话ID（如服务重启前签发）视为已终止，客户端收到404后重新初始化
+	if terminated, err := m.Validate("mcp-unknown"); !terminated || err != nil {
+		t.Errorf("Validate(unknown) = %v, %v, want terminated", terminated, err)
+	}
+
+	if notAllowed, err := m.Terminate(id); notAllowed || err != nil {
+		t.Errorf("Terminate(active) = %v, %v", notAllowed, err)
+	}
+	if terminated, err := m.Validate(id); !terminated || err != nil {
+		t.Errorf("Validate(terminated) = %v, %v, want terminated", terminated, err)
+	}
+	if notAllowed, err := m.Terminate("mcp-unknown"); notAllowed || err != nil {
+		t.Errorf("Terminate(unknown) = %v, %v", notAllowed, err)
+	}
+}
+
+func TestSessionIDManagerExpiry(t *testing.T) {
+	m := newSessionIDManager(time.Millisecond)
+	id := m.Generate()
+	time.Sleep(5 * time.Millisecond)
+	if terminated, err := m.Validate(id); !terminated || err != nil {
+		t.Errorf("Validate(expired) = %v, %v, want terminated", terminated, err)
+	}
+}
+
+func TestStreamableHTTPUnknownSessionReturns404(t *testing.T) {
+	svr := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	handler := server.NewStreamableHTTPServer(svr, server.WithSessionIdManager(newSessionIDManager(time.Hour)))
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerMCPSessionID, "mcp-issued-before-restart")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+// newStreamableTestServer 启动带会话ID校验和断线续传的streamable HTTP测试服务
+func newStreamableTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	svr := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	ids := newSessionIDManager(time.Hour)
+	handler := server.NewStreamableHTTPServer(svr, server.WithSessionIdManager(ids))
+	ts := httptest.NewServer(newResumableHandler(handler, time.Hour, ids))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// initSession 发送initialize请求，返回服务签发的会话ID
+func initSession(t *testing.T, url string) string {
+	t.Helper()
+	body := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	id := resp.Header.Get(headerMCPSessionID)
+	if id == "" {
+		t.Fatalf("initialize returned no session ID (status %d)", resp.StatusCode)
+	}
+	return id
+}
+
+// sendWithSession 以指定会话ID发送GET或DELETE请求，返回状态码（GET成功时立即断开监听流）
+func sendWithSession(t *testing.T, method, url, sessionID string) int {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, method, url, nil)
+	req.Header.Set(headerMCPSessionID, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestStreamableHTTPGetAndDeleteValidateSession(t *testing.T) {
+	ts := newStreamableTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		want   int
+	}{
+		{name: "未知会话的监听流", method: http.MethodGet, want: http.StatusNotFound},
+		{name: "删除未知会话", method: http.MethodDelete, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sendWithSession(t, tt.method, ts.URL, "mcp-issued-before-restart"); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	id := initSession(t, ts.URL)
+	if got := sendWithSession(t, http.MethodGet, ts.URL, id); got != http.StatusAccepted {
+		t.Errorf("GET with active session = %d, want %d", got, http.StatusAccepted)
+	}
+	if got := sendWithSession(t, http.MethodDelete, ts.URL, id); got != http.StatusOK {
+		t.Errorf("DELETE with active session = %d, want %d", got, http.StatusOK)
+	}
+	// 已终止的会话不能再打开监听流或重复删除
+	if got := sendWithSession(t, http.MethodGet, ts.URL, id); got != http.StatusNotFound {
+		t.Errorf("GET after DELETE = %d, want %d", got, http.StatusNotFound)
+	}
+	if got := sendWithSession(t, http.MethodDelete, ts.URL, id); got != http.StatusNotFound {
+		t.Errorf("DELETE after DELETE = %d, want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestResumableHandlerSkipsPings(t *testing.T) {
+	h := newResumableHandler(http.NotFoundHandler(), time.Hour, nil)
+	rec := httptest.NewRecorder()
+	w := &eventRecorder{ResponseWriter: rec, handler: h, sessionID: "s1"}
+
+	w.Write([]byte("event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n"))
+	for i := 0; i < maxBufferedEvents+5; i++ {
+		w.Write([]byte(fmt.Sprintf("event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%d,\"method\":\"ping\"}\n\n", i)))
+	}
+
+	// 心跳不占用缓存，也不分配事件id；之前的通知仍可补发
+	missed := h.since("s1", 0)
+	if len(missed) != 1 || missed[0].id != 1 || !strings.Contains(string(missed[0].data), "notifications/progress") {
+		t.Fatalf("since(0) = %d events, want only the progress notification", len(missed))
+	}
+	if n := strings.Count(rec.Body.String(), "id: "); n != 1 {
+		t.Errorf("wrote %d id lines, want 1", n)
+	}
+	if n := strings.Count(rec.Body.String(), `"method":"ping"`); n != maxBufferedEvents+5 {
+		t.Errorf("wrote %d pings, want %d", n, maxBufferedEvents+5)
+	}
+}
+
+func TestResumableHandlerDropsExpiredSession(t *testing.T) {
+	h := newResumableHandler(http.NotFoundHandler(), time.Millisecond, nil)
+	h.record("s1", []byte("event: message\ndata: {}\n\n"))
+	time.Sleep(5 * time.Millisecond)
+	if missed := h.since("s1", 0); len(missed) != 0 {
+		t.Errorf("since() on expired session = %d events, want none", len(missed))
+	}
+	if _, ok := h.sessions["s1"]; ok {
+		t.Error("expired session not dropped")
+	}
+}